@@ -0,0 +1,347 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything the service reads at startup. Values are layered,
+// each overriding the last: defaults, the YAML file, .env, then the process
+// environment.
+type Config struct {
+	// Environment is "development" or "production". Development relaxes
+	// checks that would be unsafe in production, such as logging mail.
+	Environment string          `yaml:"environment"` // ENVIRONMENT
+	Server      ServerConfig    `yaml:"server"`
+	Database    DatabaseConfig  `yaml:"database"`
+	Auth        AuthConfig      `yaml:"auth"`
+	Mail        MailConfig      `yaml:"mail"`
+	RateLimit   RateLimitConfig `yaml:"rate_limit"`
+	Log         LogConfig       `yaml:"log"`
+}
+
+type ServerConfig struct {
+	Port              string        `yaml:"port"`                // GIN_PORT, e.g. ":8080"
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"` // SERVER_READ_HEADER_TIMEOUT
+	ReadTimeout       time.Duration `yaml:"read_timeout"`        // SERVER_READ_TIMEOUT
+	WriteTimeout      time.Duration `yaml:"write_timeout"`       // SERVER_WRITE_TIMEOUT
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`        // SERVER_IDLE_TIMEOUT
+	// ShutdownTimeout bounds how long in-flight requests may take to drain
+	// after SIGINT or SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // SHUTDOWN_TIMEOUT
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`     // PSQL_HOST
+	Port     int    `yaml:"port"`     // PSQL_PORT
+	User     string `yaml:"user"`     // PSQL_USER
+	Password string `yaml:"password"` // PSQL_PASSWORD
+	Name     string `yaml:"name"`     // PSQL_DBNAME
+	SSLMode  string `yaml:"sslmode"`  // PSQL_SSLMODE
+
+	MaxOpenConns    int           `yaml:"max_open_conns"`    // PSQL_MAX_OPEN_CONNS
+	MaxIdleConns    int           `yaml:"max_idle_conns"`    // PSQL_MAX_IDLE_CONNS
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"` // PSQL_CONN_MAX_LIFETIME
+}
+
+// ConnInfo is the lib/pq connection string for the database.
+func (c DatabaseConfig) ConnInfo() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		c.Host, c.Port, c.User, c.Password, c.Name, c.SSLMode)
+}
+
+type AuthConfig struct {
+	TokenSecret          string        `yaml:"token_secret"`           // TOKEN_SECRET
+	RefreshTokenSecret   string        `yaml:"refresh_token_secret"`   // REFRESH_TOKEN_SECRET
+	AccessTokenTTL       time.Duration `yaml:"access_token_ttl"`       // ACCESS_TOKEN_TTL
+	RefreshTokenTTL      time.Duration `yaml:"refresh_token_ttl"`      // REFRESH_TOKEN_TTL
+	RequireVerifiedEmail bool          `yaml:"require_verified_email"` // REQUIRE_EMAIL_VERIFICATION
+}
+
+// MailConfig picks how outgoing mail is delivered: over SMTP if SMTPHost is
+// set, else as files in Dir, else only logged. The last two are for
+// development.
+type MailConfig struct {
+	SMTPHost     string `yaml:"smtp_host"`     // MAIL_SMTP_HOST
+	SMTPPort     int    `yaml:"smtp_port"`     // MAIL_SMTP_PORT
+	SMTPUsername string `yaml:"smtp_username"` // MAIL_SMTP_USERNAME
+	SMTPPassword string `yaml:"smtp_password"` // MAIL_SMTP_PASSWORD
+	From         string `yaml:"from"`          // MAIL_FROM
+	Dir          string `yaml:"dir"`           // MAIL_DIR
+}
+
+// RateLimitConfig picks where rate limit buckets live and how fast each
+// group of routes may be called by one caller.
+type RateLimitConfig struct {
+	Backend string        `yaml:"backend"` // RATE_LIMIT_BACKEND: "postgres" or "memory"
+	Auth    RateLimitRule `yaml:"auth"`    // RATE_LIMIT_AUTH_PER_MINUTE, RATE_LIMIT_AUTH_BURST
+	Read    RateLimitRule `yaml:"read"`    // RATE_LIMIT_READ_PER_MINUTE, RATE_LIMIT_READ_BURST
+	Write   RateLimitRule `yaml:"write"`   // RATE_LIMIT_WRITE_PER_MINUTE, RATE_LIMIT_WRITE_BURST
+}
+
+// RateLimitRule allows PerMinute requests a minute on average and up to Burst
+// at once.
+type RateLimitRule struct {
+	PerMinute int `yaml:"per_minute"`
+	Burst     int `yaml:"burst"`
+}
+
+type LogConfig struct {
+	Level string `yaml:"level"` // LOG_LEVEL: debug, info, warn or error
+}
+
+// SlogLevel parses Level.
+func (c LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(c.Level))
+	return level, err
+}
+
+// IsDevelopment reports whether the service runs in the development
+// environment.
+func (c Config) IsDevelopment() bool {
+	return c.Environment == "development"
+}
+
+func Default() Config {
+	return Config{
+		Environment: "production",
+		Server: ServerConfig{
+			Port:              ":8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   25 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:            "postgres",
+			Port:            5432,
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 7 * 24 * time.Hour,
+		},
+		Mail: MailConfig{SMTPPort: 587},
+		RateLimit: RateLimitConfig{
+			Backend: "postgres",
+			Auth:    RateLimitRule{PerMinute: 10, Burst: 5},
+			Read:    RateLimitRule{PerMinute: 300, Burst: 60},
+			Write:   RateLimitRule{PerMinute: 60, Burst: 20},
+		},
+		Log: LogConfig{Level: "info"},
+	}
+}
+
+// Load builds the Config and validates it. The YAML file is CONFIG_FILE if
+// set, otherwise config.yaml if present; the .env file is ENV_FILE if set,
+// otherwise .env if present.
+func Load() (Config, error) {
+	cfg := Default()
+
+	if err := loadDotEnv(); err != nil {
+		return cfg, err
+	}
+	if err := loadYAML(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// loadDotEnv copies .env into the process environment without overriding
+// variables that are already set.
+func loadDotEnv() error {
+	path, required := os.Getenv("ENV_FILE"), true
+	if path == "" {
+		path, required = ".env", false
+	}
+	err := godotenv.Load(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config: loading %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadYAML(cfg *Config) error {
+	path, required := os.Getenv("CONFIG_FILE"), true
+	if path == "" {
+		path, required = "config.yaml", false
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config: reading %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	var errs []error
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	num := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an integer", name, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a duration", name, v))
+				return
+			}
+			*dst = d
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a boolean", name, v))
+				return
+			}
+			*dst = b
+		}
+	}
+
+	str("ENVIRONMENT", &cfg.Environment)
+	str("GIN_PORT", &cfg.Server.Port)
+	duration("SERVER_READ_HEADER_TIMEOUT", &cfg.Server.ReadHeaderTimeout)
+	duration("SERVER_READ_TIMEOUT", &cfg.Server.ReadTimeout)
+	duration("SERVER_WRITE_TIMEOUT", &cfg.Server.WriteTimeout)
+	duration("SERVER_IDLE_TIMEOUT", &cfg.Server.IdleTimeout)
+	duration("SHUTDOWN_TIMEOUT", &cfg.Server.ShutdownTimeout)
+
+	str("PSQL_HOST", &cfg.Database.Host)
+	num("PSQL_PORT", &cfg.Database.Port)
+	str("PSQL_USER", &cfg.Database.User)
+	str("PSQL_PASSWORD", &cfg.Database.Password)
+	str("PSQL_DBNAME", &cfg.Database.Name)
+	str("PSQL_SSLMODE", &cfg.Database.SSLMode)
+	num("PSQL_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	num("PSQL_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns)
+	duration("PSQL_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime)
+
+	str("TOKEN_SECRET", &cfg.Auth.TokenSecret)
+	str("REFRESH_TOKEN_SECRET", &cfg.Auth.RefreshTokenSecret)
+	duration("ACCESS_TOKEN_TTL", &cfg.Auth.AccessTokenTTL)
+	duration("REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTokenTTL)
+	boolean("REQUIRE_EMAIL_VERIFICATION", &cfg.Auth.RequireVerifiedEmail)
+
+	str("MAIL_SMTP_HOST", &cfg.Mail.SMTPHost)
+	num("MAIL_SMTP_PORT", &cfg.Mail.SMTPPort)
+	str("MAIL_SMTP_USERNAME", &cfg.Mail.SMTPUsername)
+	str("MAIL_SMTP_PASSWORD", &cfg.Mail.SMTPPassword)
+	str("MAIL_FROM", &cfg.Mail.From)
+	str("MAIL_DIR", &cfg.Mail.Dir)
+	str("RATE_LIMIT_BACKEND", &cfg.RateLimit.Backend)
+	num("RATE_LIMIT_AUTH_PER_MINUTE", &cfg.RateLimit.Auth.PerMinute)
+	num("RATE_LIMIT_AUTH_BURST", &cfg.RateLimit.Auth.Burst)
+	num("RATE_LIMIT_READ_PER_MINUTE", &cfg.RateLimit.Read.PerMinute)
+	num("RATE_LIMIT_READ_BURST", &cfg.RateLimit.Read.Burst)
+	num("RATE_LIMIT_WRITE_PER_MINUTE", &cfg.RateLimit.Write.PerMinute)
+	num("RATE_LIMIT_WRITE_BURST", &cfg.RateLimit.Write.Burst)
+	str("LOG_LEVEL", &cfg.Log.Level)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every missing or malformed value that any command needs,
+// all at once. Settings only the server uses are checked by ValidateServer.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Environment != "development" && c.Environment != "production" {
+		errs = append(errs, fmt.Errorf("ENVIRONMENT must be development or production, not %q", c.Environment))
+	}
+	if c.Database.Host == "" {
+		errs = append(errs, errors.New("PSQL_HOST must be set"))
+	}
+	if c.Database.Port <= 0 || c.Database.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PSQL_PORT %d is out of range", c.Database.Port))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 || c.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("pool settings must not be negative"))
+	}
+	if _, err := c.Log.SlogLevel(); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, not %q", c.Log.Level))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// ValidateServer reports every missing or malformed value the HTTP server
+// needs on top of Validate. Migrations run without them.
+func (c Config) ValidateServer() error {
+	var errs []error
+	if c.Auth.TokenSecret == "" {
+		errs = append(errs, errors.New("TOKEN_SECRET must be set"))
+	}
+	if c.Auth.RefreshTokenSecret == "" {
+		errs = append(errs, errors.New("REFRESH_TOKEN_SECRET must be set"))
+	}
+	if c.Auth.AccessTokenTTL <= 0 || c.Auth.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("token TTLs must be positive"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if c.Mail.SMTPHost == "" && !c.IsDevelopment() {
+		errs = append(errs, errors.New("MAIL_SMTP_HOST must be set outside development"))
+	}
+	if c.Mail.SMTPHost != "" {
+		if c.Mail.From == "" {
+			errs = append(errs, errors.New("MAIL_FROM must be set with MAIL_SMTP_HOST"))
+		}
+		if c.Mail.SMTPPort <= 0 || c.Mail.SMTPPort > 65535 {
+			errs = append(errs, fmt.Errorf("MAIL_SMTP_PORT %d is out of range", c.Mail.SMTPPort))
+		}
+	}
+	if c.RateLimit.Backend != "postgres" && c.RateLimit.Backend != "memory" {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BACKEND must be postgres or memory, not %q", c.RateLimit.Backend))
+	}
+	for _, rule := range []struct {
+		name string
+		RateLimitRule
+	}{{"AUTH", c.RateLimit.Auth}, {"READ", c.RateLimit.Read}, {"WRITE", c.RateLimit.Write}} {
+		if rule.PerMinute <= 0 || rule.Burst <= 0 {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_%s_PER_MINUTE and RATE_LIMIT_%s_BURST must be positive", rule.name, rule.name))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
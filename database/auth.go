@@ -1,13 +1,8 @@
 package database
 
 import (
-	"context"
-	"net/http"
-	"os"
-	"strings"
-	"time"
+	"fuzzy-succotash-balance/main.go/config"
 
-	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -22,33 +17,38 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-var AccessTokenSecret = []byte(os.Getenv("TOKEN_SECRET"))
-var RefreshTokenSecret = []byte(os.Getenv("REFRESH_TOKEN_SECRET"))
-var AccessTokenTTL = time.Minute * 15
-var RefreshTokenTTL = time.Hour * 24 * 7
-
 type UserClaims struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	Role  Role   `json:"role"`
 	jwt.StandardClaims
 }
 
-func NewAccessToken(claims UserClaims) (string, error) {
+func (u *UserClaims) HasRole(roles ...Role) bool {
+	for _, role := range roles {
+		if u.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func NewAccessToken(auth config.AuthConfig, claims UserClaims) (string, error) {
 	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	return accessToken.SignedString([]byte(os.Getenv("TOKEN_SECRET")))
+	return accessToken.SignedString([]byte(auth.TokenSecret))
 }
 
-func NewRefreshToken(claims jwt.StandardClaims) (string, error) {
+func NewRefreshToken(auth config.AuthConfig, claims jwt.StandardClaims) (string, error) {
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	return refreshToken.SignedString([]byte(os.Getenv("TOKEN_SECRET")))
+	return refreshToken.SignedString([]byte(auth.RefreshTokenSecret))
 }
 
-func ParseAccessToken(accessToken string) *UserClaims {
+func ParseAccessToken(auth config.AuthConfig, accessToken string) *UserClaims {
 	parsedAccessToken, err := jwt.ParseWithClaims(accessToken, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("TOKEN_SECRET")), nil
+		return []byte(auth.TokenSecret), nil
 	})
 	if err != nil || !parsedAccessToken.Valid {
 		return nil
@@ -57,74 +57,18 @@ func ParseAccessToken(accessToken string) *UserClaims {
 	return parsedAccessToken.Claims.(*UserClaims)
 }
 
-func ParseRefreshToken(refreshToken string) *jwt.StandardClaims {
+func ParseRefreshToken(auth config.AuthConfig, refreshToken string) *jwt.StandardClaims {
 	parsedRefreshToken, err := jwt.ParseWithClaims(refreshToken, &jwt.StandardClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("TOKEN_SECRET")), nil
+		return []byte(auth.RefreshTokenSecret), nil
 	})
 	if err != nil || !parsedRefreshToken.Valid {
 		return nil
 	}
 
-	return parsedRefreshToken.Claims.(*jwt.StandardClaims)
-}
-
-type ContextKey string
-
-const UserIDKey ContextKey = "userID"
-
-type VerifyRefreshRequest struct {
-	ID    string `json:"id"`
-	Token string `json:"token"`
-}
-
-func VerifyJWT() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		if strings.HasPrefix(c.Request.URL.Path, "/register") || strings.HasPrefix(c.Request.URL.Path, "/login") || strings.HasPrefix(c.Request.URL.Path, "/health") {
-			c.Next()
-			return
-		}
-
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication Header is missing!"})
-			c.Abort()
-			return
-		}
-
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		userClaims := ParseAccessToken(token)
-		if userClaims == nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to verify token!"})
-			c.Abort()
-			return
-		}
-
-		c.Set("userClaims", userClaims)
-
-		c.Next()
+	claims := parsedRefreshToken.Claims.(*jwt.StandardClaims)
+	if claims.Id == "" || claims.Subject == "" {
+		return nil
 	}
-}
 
-func VerifyRefreshToken() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var req VerifyRefreshRequest
-
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-			c.Abort()
-			return
-		}
-
-		claims := ParseRefreshToken(req.Token)
-		if claims == nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to verify token!"})
-			c.Abort()
-			return
-		}
-
-		ctx := context.WithValue(c.Request.Context(), UserIDKey, req.ID)
-		c.Request = c.Request.WithContext(ctx)
-
-		c.Next()
-	}
+	return claims
 }
@@ -1,51 +1,35 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/rand"
-	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 )
 
-func CreateChatsTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS chats (
-		chat_id TEXT PRIMARY KEY,
-		users TEXT[] NOT NULL,
-		messages TEXT[] DEFAULT '{}',
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);`
-
-	_, err := db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("could not create chats table: %w", err)
-	}
-	return nil
-}
+// ChatPreviewLength is how many characters of a chat's last message
+// ChatSummary carries.
+const ChatPreviewLength = 100
 
-func CreateMessagesTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS messages (
-		message_id TEXT PRIMARY KEY,
-		chat_id TEXT NOT NULL REFERENCES chats(chat_id) ON DELETE CASCADE,
-		sender TEXT NOT NULL,
-		text TEXT,
-		media TEXT[] DEFAULT '{}',
-		created_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);`
-
-	_, err := db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("could not create messages table: %w", err)
+var (
+	errOwnerRole      = invalid("The chat owner's role cannot be changed")
+	errMessageDeleted = invalid("Message has been deleted")
+	errReaction       = invalid("Reaction must be a single emoji")
+)
+
+func previewText(text string) string {
+	runes := []rune(text)
+	if len(runes) <= ChatPreviewLength {
+		return text
 	}
-	return nil
+	return string(runes[:ChatPreviewLength]) + "…"
 }
 
 func GenerateChatID(t time.Time) string {
@@ -87,187 +71,511 @@ func GenerateMessageID(senderUUID string) (string, error) {
 	return messageID, nil
 }
 
-func CreateChat(db *sql.DB, c *gin.Context) {
+// Membership lives in chat_members; these subqueries fold it back into the
+// Chat's Owner, Admins and Users.
+const chatColumns = `chats.chat_id,
+	COALESCE((SELECT user_id FROM chat_members m WHERE m.chat_id = chats.chat_id AND m.role = 'owner'), ''),
+	ARRAY(SELECT user_id FROM chat_members m WHERE m.chat_id = chats.chat_id AND m.role = 'admin' ORDER BY m.joined_at, m.user_id),
+	ARRAY(SELECT user_id FROM chat_members m WHERE m.chat_id = chats.chat_id ORDER BY m.joined_at, m.user_id),
+	chats.created_at, chats.updated_at`
+
+func scanChat(row scanner) (Chat, error) {
 	var chat Chat
-	if err := c.ShouldBindJSON(&chat); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	err := row.Scan(&chat.ChatID, &chat.Owner, pq.Array(&chat.Admins), pq.Array(&chat.Users), &chat.CreatedAt, &chat.UpdatedAt)
+	return chat, err
+}
+
+// messageColumns aggregates reactions into a JSON array of ReactionCount,
+// ordered by when each emoji was first used.
+const messageColumns = `messages.message_id, messages.chat_id, messages.sender, messages.text, messages.media,
+	COALESCE((
+		SELECT json_agg(json_build_object('emoji', r.emoji, 'count', r.count) ORDER BY r.first_at, r.emoji)
+		FROM (
+			SELECT emoji, COUNT(*) AS count, MIN(created_at) AS first_at FROM message_reactions
+			WHERE message_id = messages.message_id GROUP BY emoji
+		) r
+	), '[]'),
+	messages.created_at, messages.edited_at, messages.deleted_at`
+
+func scanMessage(row scanner) (Message, error) {
+	var msg Message
+	var reactions []byte
+	err := row.Scan(&msg.MessageID, &msg.Chat, &msg.Sender, &msg.Text, pq.Array(&msg.Media), &reactions,
+		&msg.CreatedAt, &msg.EditedAt, &msg.DeletedAt)
+	if err != nil {
+		return msg, err
 	}
-	var time = time.Now()
-	chat.ChatID = GenerateChatID(time)
+	err = json.Unmarshal(reactions, &msg.Reactions)
+	return msg, err
+}
+
+type pgChatStore struct {
+	db *sql.DB
+}
 
-	query := `INSERT INTO chats (chat_id, users, messages, created_at, updated_at)
-	          VALUES ($1, $2, $3, NOW(), NOW())`
+func NewPostgresChatStore(db *sql.DB) ChatStore {
+	return &pgChatStore{db: db}
+}
 
-	_, err := db.ExecContext(c, query, chat.ChatID, pq.Array(chat.Users), pq.Array(chat.Messages))
+func (s *pgChatStore) CreateChat(ctx context.Context, chat Chat) error {
+	defer observe("chats", "CreateChat", time.Now())
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO chats (chat_id, created_at, updated_at) VALUES ($1, NOW(), NOW())`
+	if _, err := tx.ExecContext(ctx, query, chat.ChatID); err != nil {
+		return mapError(err, "Chat")
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Chat created successfully"})
+	memberQuery := `INSERT INTO chat_members (chat_id, user_id, role, joined_at) VALUES ($1, $2, $3, NOW())`
+	for _, userID := range chat.Users {
+		role, _ := chat.RoleOf(userID)
+		if _, err := tx.ExecContext(ctx, memberQuery, chat.ChatID, userID, role); err != nil {
+			return mapError(err, "Chat member")
+		}
+	}
+	return tx.Commit()
 }
 
-func CreateMessage(db *sql.DB, c *gin.Context) {
-	var msg Message
-	if err := c.ShouldBindJSON(&msg); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+func (s *pgChatStore) GetChat(ctx context.Context, chatID string) (Chat, error) {
+	defer observe("chats", "GetChat", time.Now())
+	query := `SELECT ` + chatColumns + ` FROM chats WHERE chat_id=$1`
+	chat, err := scanChat(s.db.QueryRowContext(ctx, query, chatID))
+	if err == sql.ErrNoRows {
+		return chat, notFound("Chat")
 	}
-	messageID, err := GenerateMessageID(msg.Sender)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	return chat, err
+}
+
+var chatList = listSpec[Chat]{
+	selectSQL: `SELECT ` + chatColumns + ` FROM chats`,
+	idColumn:  "chat_id",
+	idCast:    "text",
+	id:        func(c Chat) string { return c.ChatID },
+	sorts: map[string]sortField[Chat]{
+		"created_at": {
+			column: "created_at", cast: "timestamp",
+			value: func(c Chat) string { return c.CreatedAt.Format(time.RFC3339Nano) },
+			less:  func(a, b Chat) bool { return a.CreatedAt.Before(b.CreatedAt) },
+		},
+		"updated_at": {
+			column: "updated_at", cast: "timestamp",
+			value: func(c Chat) string { return c.UpdatedAt.Format(time.RFC3339Nano) },
+			less:  func(a, b Chat) bool { return a.UpdatedAt.Before(b.UpdatedAt) },
+		},
+	},
+	defaultSort: "created_at",
+}
+
+const memberOf = "chats.chat_id IN (SELECT chat_id FROM chat_members WHERE user_id = ?)"
+
+func (s *pgChatStore) ListChats(ctx context.Context, filter ChatFilter, page PageRequest) (Page[Chat], error) {
+	defer observe("chats", "ListChats", time.Now())
+	var b queryBuilder
+	if filter.Member != "" {
+		b.where(memberOf, filter.Member)
 	}
-	msg.MessageID = messageID
+	return queryPage(ctx, s.db, chatList, b, page, scanChat)
+}
 
-	query := `INSERT INTO messages (message_id, chat_id, sender, text, media, created_at)
-	          VALUES ($1, $2, $3, $4, $5, NOW())`
+// The latest message of each chat, found through messages(chat_id,
+// created_at).
+const chatSummaryFrom = ` FROM chats LEFT JOIN LATERAL (
+		SELECT message_id, sender, text, media, created_at FROM messages
+		WHERE messages.chat_id = chats.chat_id
+		ORDER BY created_at DESC, message_id DESC LIMIT 1
+	) last ON true`
+
+var chatSummaryList = listSpec[ChatSummary]{
+	selectSQL: `SELECT ` + chatColumns + `, last.message_id, last.sender, last.text, last.media, last.created_at` + chatSummaryFrom,
+	idColumn:  "chats.chat_id",
+	idCast:    "text",
+	id:        func(c ChatSummary) string { return c.ChatID },
+	sorts: map[string]sortField[ChatSummary]{
+		"last_activity_at": {
+			column: "COALESCE(last.created_at, chats.created_at)", cast: "timestamp",
+			value: func(c ChatSummary) string { return c.LastActivityAt.Format(time.RFC3339Nano) },
+			less:  func(a, b ChatSummary) bool { return a.LastActivityAt.Before(b.LastActivityAt) },
+		},
+	},
+	defaultSort: "last_activity_at",
+}
 
-	_, err = db.ExecContext(c, query, msg.MessageID, msg.Chat, msg.Sender, msg.Text, pq.Array(msg.Media))
+func scanChatSummary(row scanner) (ChatSummary, error) {
+	var summary ChatSummary
+	var messageID, sender, text sql.NullString
+	var media []string
+	var sentAt sql.NullTime
+	err := row.Scan(&summary.ChatID, &summary.Owner, pq.Array(&summary.Admins), pq.Array(&summary.Users), &summary.CreatedAt, &summary.UpdatedAt,
+		&messageID, &sender, &text, pq.Array(&media), &sentAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return summary, err
+	}
+
+	summary.LastActivityAt = summary.CreatedAt
+	if messageID.Valid {
+		summary.LastMessage = &Message{
+			MessageID: messageID.String,
+			Chat:      summary.ChatID,
+			Sender:    sender.String,
+			Text:      previewText(text.String),
+			Media:     media,
+			CreatedAt: sentAt.Time,
+		}
+		summary.LastActivityAt = sentAt.Time
 	}
+	return summary, nil
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Message created successfully"})
+func (s *pgChatStore) ListChatSummaries(ctx context.Context, filter ChatFilter, page PageRequest) (Page[ChatSummary], error) {
+	defer observe("chats", "ListChatSummaries", time.Now())
+	var b queryBuilder
+	if filter.Member != "" {
+		b.where(memberOf, filter.Member)
+	}
+	return queryPage(ctx, s.db, chatSummaryList, b, page, scanChatSummary)
 }
 
-func GetAllChats(db *sql.DB, c *gin.Context) {
-	query := `SELECT chat_id, users, messages, created_at, updated_at FROM chats`
-	rows, err := db.QueryContext(c, query)
+func (s *pgChatStore) DeleteChat(ctx context.Context, chatID string) error {
+	defer observe("chats", "DeleteChat", time.Now())
+	result, err := s.db.ExecContext(ctx, `DELETE FROM chats WHERE chat_id=$1`, chatID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
-	defer rows.Close()
+	return rowsAffectedOrNotFound(result, "Chat")
+}
 
-	var chats []Chat
-	for rows.Next() {
-		var chat Chat
-		if err := rows.Scan(&chat.ChatID, pq.Array(&chat.Users), pq.Array(&chat.Messages), &chat.CreatedAt, &chat.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		chats = append(chats, chat)
+func (s *pgChatStore) IsMember(ctx context.Context, chatID string, userID string) (bool, error) {
+	defer observe("chats", "IsMember", time.Now())
+	var member bool
+	query := `SELECT EXISTS (SELECT 1 FROM chat_members m WHERE m.chat_id = chats.chat_id AND m.user_id = $2)
+		FROM chats WHERE chat_id = $1`
+	err := s.db.QueryRowContext(ctx, query, chatID, userID).Scan(&member)
+	if err == sql.ErrNoRows {
+		return false, notFound("Chat")
 	}
+	return member, err
+}
 
-	c.JSON(http.StatusOK, chats)
+func (s *pgChatStore) SetMember(ctx context.Context, chatID string, userID string, role ChatRole) error {
+	defer observe("chats", "SetMember", time.Now())
+	if role != ChatAdmin && role != ChatMember {
+		return invalid("Role must be %s or %s", ChatAdmin, ChatMember)
+	}
+	query := `WITH member AS (
+			INSERT INTO chat_members (chat_id, user_id, role, joined_at) VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (chat_id, user_id) DO UPDATE SET role = EXCLUDED.role
+			WHERE chat_members.role <> 'owner'
+			RETURNING chat_id
+		)
+		UPDATE chats SET updated_at = NOW() WHERE chat_id IN (SELECT chat_id FROM member)`
+	result, err := s.db.ExecContext(ctx, query, chatID, userID, role)
+	if err != nil {
+		return mapError(err, "Chat member")
+	}
+	// The upsert only skips a row when userID owns the chat.
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errOwnerRole
+	}
+	return nil
+}
+
+func (s *pgChatStore) RemoveMember(ctx context.Context, chatID string, userID string) error {
+	defer observe("chats", "RemoveMember", time.Now())
+	query := `WITH removed AS (
+			DELETE FROM chat_members WHERE chat_id = $1 AND user_id = $2 RETURNING chat_id
+		)
+		UPDATE chats SET updated_at = NOW() WHERE chat_id IN (SELECT chat_id FROM removed)`
+	result, err := s.db.ExecContext(ctx, query, chatID, userID)
+	if err != nil {
+		return err
+	}
+	return rowsAffectedOrNotFound(result, "Chat member")
 }
 
-func GetChatWithMessages(db *sql.DB, c *gin.Context) {
-	chatID := c.Param("chatID")
+func (s *pgChatStore) CreateMessage(ctx context.Context, msg Message) error {
+	defer observe("chats", "CreateMessage", time.Now())
+	query := `INSERT INTO messages (message_id, chat_id, sender, text, media, created_at)
+	          VALUES ($1, $2, $3, $4, $5, NOW())`
 
-	var chat Chat
-	chatQuery := `SELECT chat_id, users, messages, created_at, updated_at FROM chats WHERE chat_id=$1`
-	err := db.QueryRowContext(c, chatQuery, chatID).Scan(&chat.ChatID, pq.Array(&chat.Users), pq.Array(&chat.Messages), &chat.CreatedAt, &chat.UpdatedAt)
+	_, err := s.db.ExecContext(ctx, query, msg.MessageID, msg.Chat, msg.Sender, msg.Text, pq.Array(msg.Media))
+	return mapError(err, "Message")
+}
+
+func (s *pgChatStore) GetMessage(ctx context.Context, messageID string) (Message, error) {
+	defer observe("chats", "GetMessage", time.Now())
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE message_id=$1`
+	msg, err := scanMessage(s.db.QueryRowContext(ctx, query, messageID))
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return msg, notFound("Message")
+	}
+	return msg, err
+}
+
+func (s *pgChatStore) ListMessages(ctx context.Context, chatID string, req MessagePageRequest) (MessagePage, error) {
+	defer observe("chats", "ListMessages", time.Now())
+	if req.Before != "" && req.After != "" {
+		return MessagePage{}, invalid("Only one of before and after may be set")
 	}
+	limit := pageLimit(req.Limit)
 
-	messagesQuery := `SELECT message_id, chat_id, sender, text, media, created_at FROM messages WHERE chat_id=$1 ORDER BY created_at ASC`
-	rows, err := db.QueryContext(c, messagesQuery, chatID)
+	var b queryBuilder
+	b.where("messages.chat_id = ?", chatID)
+
+	// Walk away from the cursor: backwards for before and the latest page,
+	// forwards for after.
+	cursorID, direction, comparison := req.Before, "DESC", "<"
+	if req.After != "" {
+		cursorID, direction, comparison = req.After, "ASC", ">"
+	}
+	if cursorID != "" {
+		var cursorAt time.Time
+		cursorQuery := `SELECT created_at FROM messages WHERE message_id=$1 AND chat_id=$2`
+		err := s.db.QueryRowContext(ctx, cursorQuery, cursorID, chatID).Scan(&cursorAt)
+		if err == sql.ErrNoRows {
+			return MessagePage{}, notFound("Message")
+		}
+		if err != nil {
+			return MessagePage{}, err
+		}
+		b.where("(messages.created_at, messages.message_id) "+comparison+" (?, ?)", cursorAt, cursorID)
+	}
+
+	b.args = append(b.args, limit+1)
+	query := `SELECT ` + messageColumns + ` FROM messages` + b.whereSQL() +
+		` ORDER BY messages.created_at ` + direction + `, messages.message_id ` + direction +
+		` LIMIT $` + strconv.Itoa(len(b.args))
+	rows, err := s.db.QueryContext(ctx, query, b.args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return MessagePage{}, err
 	}
 	defer rows.Close()
 
 	var messages []Message
 	for rows.Next() {
-		var msg Message
-		if err := rows.Scan(&msg.MessageID, &msg.Chat, &msg.Sender, &msg.Text, pq.Array(&msg.Media), &msg.CreatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return MessagePage{}, err
 		}
 		messages = append(messages, msg)
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"chat":     chat,
-		"messages": messages,
-	})
+	if err := rows.Err(); err != nil {
+		return MessagePage{}, err
+	}
+	return newMessagePage(messages, req, limit), nil
 }
 
-func GetChatByID(db *sql.DB, c *gin.Context) {
-	chatID := c.Param("chatID")
+func (s *pgChatStore) EditMessage(ctx context.Context, messageID string, text string, media []string) (Message, error) {
+	defer observe("chats", "EditMessage", time.Now())
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Message{}, err
+	}
+	defer tx.Rollback()
 
-	query := `SELECT chat_id, users, messages, created_at, updated_at FROM chats WHERE chat_id=$1`
-	var chat Chat
-	err := db.QueryRowContext(c, query, chatID).Scan(&chat.ChatID, pq.Array(&chat.Users), pq.Array(&chat.Messages), &chat.CreatedAt, &chat.UpdatedAt)
+	var old MessageEdit
+	var oldText sql.NullString
+	var deletedAt sql.NullTime
+	query := `SELECT text, media, deleted_at FROM messages WHERE message_id=$1 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, messageID).Scan(&oldText, pq.Array(&old.Media), &deletedAt)
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return Message{}, notFound("Message")
+	}
+	if err != nil {
+		return Message{}, err
+	}
+	if deletedAt.Valid {
+		return Message{}, errMessageDeleted
+	}
+	old.Text = oldText.String
+	if old.Media == nil {
+		old.Media = []string{}
 	}
 
-	c.JSON(http.StatusOK, chat)
-}
-
-func UpdateChatByID(db *sql.DB, c *gin.Context) {
-	chatID := c.Param("chatID")
-	var chat Chat
-	if err := c.ShouldBindJSON(&chat); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	historyQuery := `INSERT INTO message_edits (message_id, text, media, edited_at) VALUES ($1, $2, $3, NOW())`
+	if _, err := tx.ExecContext(ctx, historyQuery, messageID, old.Text, pq.Array(old.Media)); err != nil {
+		return Message{}, err
+	}
+	updateQuery := `UPDATE messages SET text=$2, media=$3, edited_at=NOW() WHERE message_id=$1`
+	if _, err := tx.ExecContext(ctx, updateQuery, messageID, text, pq.Array(media)); err != nil {
+		return Message{}, err
 	}
+	if err := tx.Commit(); err != nil {
+		return Message{}, err
+	}
+	return s.GetMessage(ctx, messageID)
+}
 
-	query := `UPDATE chats SET users=$1, messages=$2, updated_at=NOW() WHERE chat_id=$3`
-	result, err := db.ExecContext(c, query, pq.Array(chat.Users), pq.Array(chat.Messages), chatID)
+func (s *pgChatStore) MessageEdits(ctx context.Context, messageID string) ([]MessageEdit, error) {
+	defer observe("chats", "MessageEdits", time.Now())
+	query := `SELECT text, media, edited_at FROM message_edits WHERE message_id=$1 ORDER BY edited_at, id`
+	rows, err := s.db.QueryContext(ctx, query, messageID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
-		return
+	edits := []MessageEdit{}
+	for rows.Next() {
+		var edit MessageEdit
+		if err := rows.Scan(&edit.Text, pq.Array(&edit.Media), &edit.EditedAt); err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Chat updated successfully"})
+	if len(edits) == 0 {
+		if _, err := s.GetMessage(ctx, messageID); err != nil {
+			return nil, err
+		}
+	}
+	return edits, nil
 }
 
-func DeleteChatByID(db *sql.DB, c *gin.Context) {
-	chatID := c.Param("chatID")
-
-	query := `DELETE FROM chats WHERE chat_id=$1`
-	result, err := db.ExecContext(c, query, chatID)
+func (s *pgChatStore) DeleteMessage(ctx context.Context, messageID string) error {
+	defer observe("chats", "DeleteMessage", time.Now())
+	query := `WITH reactions AS (
+			DELETE FROM message_reactions WHERE message_id = $1
+		), history AS (
+			DELETE FROM message_edits WHERE message_id = $1
+		)
+		UPDATE messages SET text = '', media = '{}', deleted_at = COALESCE(deleted_at, NOW())
+		WHERE message_id = $1`
+	result, err := s.db.ExecContext(ctx, query, messageID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
+	return rowsAffectedOrNotFound(result, "Message")
+}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
-		return
+func (s *pgChatStore) AddReaction(ctx context.Context, messageID string, userID string, emoji string) error {
+	defer observe("chats", "AddReaction", time.Now())
+	if !ValidReaction(emoji) {
+		return errReaction
+	}
+	query := `WITH msg AS (
+			SELECT message_id, deleted_at FROM messages WHERE message_id = $1
+		), added AS (
+			INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
+			SELECT message_id, $2, $3, NOW() FROM msg WHERE deleted_at IS NULL
+			ON CONFLICT DO NOTHING
+			RETURNING 1
+		)
+		SELECT deleted_at IS NOT NULL FROM msg`
+	var deleted bool
+	err := s.db.QueryRowContext(ctx, query, messageID, userID, emoji).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		return notFound("Message")
+	}
+	if err != nil {
+		return mapError(err, "Reaction")
+	}
+	if deleted {
+		return errMessageDeleted
 	}
+	return nil
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Chat deleted successfully"})
+func (s *pgChatStore) RemoveReaction(ctx context.Context, messageID string, userID string, emoji string) error {
+	defer observe("chats", "RemoveReaction", time.Now())
+	query := `DELETE FROM message_reactions WHERE message_id=$1 AND user_id=$2 AND emoji=$3`
+	result, err := s.db.ExecContext(ctx, query, messageID, userID, emoji)
+	if err != nil {
+		return err
+	}
+	return rowsAffectedOrNotFound(result, "Reaction")
 }
 
-func DeleteMessageByID(db *sql.DB, c *gin.Context) {
-	messageID := c.Param("messageID")
+func (s *pgChatStore) MarkRead(ctx context.Context, chatID string, userID string, messageID string) (ReadReceipt, error) {
+	defer observe("chats", "MarkRead", time.Now())
+	// The outer SELECT sees chat_members as it was before the update, so the
+	// new position comes from moved when there is one.
+	query := `WITH target AS (
+			SELECT message_id, created_at FROM messages
+			WHERE chat_id = $1 AND ($3 = '' OR message_id = $3)
+			ORDER BY created_at DESC, message_id DESC LIMIT 1
+		), moved AS (
+			UPDATE chat_members m SET last_read_message_id = target.message_id, last_read_at = target.created_at
+			FROM target
+			WHERE m.chat_id = $1 AND m.user_id = $2
+				AND (m.last_read_at IS NULL OR (target.created_at, target.message_id) > (m.last_read_at, m.last_read_message_id))
+			RETURNING m.last_read_message_id, m.last_read_at
+		)
+		SELECT EXISTS (SELECT 1 FROM target),
+			COALESCE((SELECT last_read_message_id FROM moved), m.last_read_message_id, ''),
+			COALESCE((SELECT last_read_at FROM moved), m.last_read_at)
+		FROM chat_members m WHERE m.chat_id = $1 AND m.user_id = $2`
+	receipt := ReadReceipt{UserID: userID}
+	var found bool
+	var readAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, chatID, userID, messageID).Scan(&found, &receipt.LastReadMessageID, &readAt)
+	if err == sql.ErrNoRows {
+		return receipt, notFound("Chat member")
+	}
+	if err != nil {
+		return receipt, err
+	}
+	if !found && messageID != "" {
+		return receipt, notFound("Message")
+	}
+	receipt.LastReadAt = readAt.Time
+	return receipt, nil
+}
 
-	query := `DELETE FROM messages WHERE message_id=$1`
-	result, err := db.ExecContext(c, query, messageID)
+func (s *pgChatStore) UnreadCounts(ctx context.Context, userID string, chatIDs []string) (map[string]int, error) {
+	defer observe("chats", "UnreadCounts", time.Now())
+	query := `SELECT m.chat_id, COUNT(msg.message_id)
+		FROM chat_members m
+		LEFT JOIN messages msg ON msg.chat_id = m.chat_id AND msg.sender <> m.user_id AND msg.deleted_at IS NULL
+			AND (m.last_read_at IS NULL OR (msg.created_at, msg.message_id) > (m.last_read_at, m.last_read_message_id))
+		WHERE m.user_id = $1 AND m.chat_id = ANY($2)
+		GROUP BY m.chat_id`
+	rows, err := s.db.QueryContext(ctx, query, userID, pq.Array(chatIDs))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var chatID string
+		var count int
+		if err := rows.Scan(&chatID, &count); err != nil {
+			return nil, err
+		}
+		counts[chatID] = count
 	}
+	return counts, rows.Err()
+}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
-		return
+func (s *pgChatStore) ReadReceipts(ctx context.Context, chatID string) ([]ReadReceipt, error) {
+	defer observe("chats", "ReadReceipts", time.Now())
+	query := `SELECT user_id, last_read_message_id, last_read_at FROM chat_members
+		WHERE chat_id = $1 AND last_read_message_id IS NOT NULL
+		ORDER BY user_id`
+	rows, err := s.db.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	c.JSON(http.StatusOK, gin.H{"message": "Message deleted successfully"})
+	var receipts []ReadReceipt
+	for rows.Next() {
+		var receipt ReadReceipt
+		if err := rows.Scan(&receipt.UserID, &receipt.LastReadMessageID, &receipt.LastReadAt); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, rows.Err()
 }
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestListMessages(t *testing.T) {
+	ctx := context.Background()
+	chats := NewMemoryChatStore()
+	if err := chats.CreateChat(ctx, Chat{ChatID: "chat-1", Owner: "user-1", Users: []string{"user-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		msg := Message{MessageID: fmt.Sprintf("m%d", i), Chat: "chat-1", Sender: "user-1", Text: "hi"}
+		if err := chats.CreateMessage(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		chatID        string
+		req           MessagePageRequest
+		want          []string
+		hasMoreBefore bool
+		hasMoreAfter  bool
+		wantErr       error
+	}{
+		{name: "latest", req: MessagePageRequest{Limit: 2}, want: []string{"m5", "m4"}, hasMoreBefore: true},
+		{name: "everything", req: MessagePageRequest{}, want: []string{"m5", "m4", "m3", "m2", "m1"}},
+		{name: "exact fit", req: MessagePageRequest{Limit: 5}, want: []string{"m5", "m4", "m3", "m2", "m1"}},
+		{name: "before", req: MessagePageRequest{Before: "m4", Limit: 2}, want: []string{"m3", "m2"}, hasMoreBefore: true, hasMoreAfter: true},
+		{name: "before reaching the start", req: MessagePageRequest{Before: "m3", Limit: 2}, want: []string{"m2", "m1"}, hasMoreAfter: true},
+		{name: "before the oldest", req: MessagePageRequest{Before: "m1"}, want: []string{}, hasMoreAfter: true},
+		{name: "after", req: MessagePageRequest{After: "m1", Limit: 2}, want: []string{"m3", "m2"}, hasMoreBefore: true, hasMoreAfter: true},
+		{name: "after reaching the end", req: MessagePageRequest{After: "m3", Limit: 2}, want: []string{"m5", "m4"}, hasMoreBefore: true},
+		{name: "after the newest", req: MessagePageRequest{After: "m5"}, want: []string{}, hasMoreBefore: true},
+		{name: "both cursors", req: MessagePageRequest{Before: "m4", After: "m2"}, wantErr: ErrInvalid},
+		{name: "unknown cursor", req: MessagePageRequest{Before: "m9"}, wantErr: ErrNotFound},
+		{name: "other chat's history", chatID: "chat-2", req: MessagePageRequest{Limit: 2}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatID := tt.chatID
+			if chatID == "" {
+				chatID = "chat-1"
+			}
+			page, err := chats.ListMessages(ctx, chatID, tt.req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ListMessages error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListMessages: %v", err)
+			}
+
+			got := []string{}
+			for _, msg := range page.Messages {
+				got = append(got, msg.MessageID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("messages = %v, want %v", got, tt.want)
+			}
+			if page.HasMoreBefore != tt.hasMoreBefore || page.HasMoreAfter != tt.hasMoreAfter {
+				t.Errorf("has more before/after = %t/%t, want %t/%t", page.HasMoreBefore, page.HasMoreAfter, tt.hasMoreBefore, tt.hasMoreAfter)
+			}
+		})
+	}
+}
+
+// TestListMessagesWalk pages through the whole history in both directions
+// and checks every message is seen exactly once.
+func TestListMessagesWalk(t *testing.T) {
+	ctx := context.Background()
+	chats := NewMemoryChatStore()
+	if err := chats.CreateChat(ctx, Chat{ChatID: "chat-1", Owner: "user-1", Users: []string{"user-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	var all []string
+	for i := 0; i < 23; i++ {
+		id := fmt.Sprintf("m%02d", i)
+		if err := chats.CreateMessage(ctx, Message{MessageID: id, Chat: "chat-1", Sender: "user-1", Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, id)
+	}
+
+	for _, limit := range []int{1, 4, 5, 23, 50} {
+		var older []string
+		req := MessagePageRequest{Limit: limit}
+		for {
+			page, err := chats.ListMessages(ctx, "chat-1", req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, msg := range page.Messages {
+				older = append(older, msg.MessageID)
+			}
+			if !page.HasMoreBefore {
+				break
+			}
+			req = MessagePageRequest{Before: older[len(older)-1], Limit: limit}
+		}
+		slices.Reverse(older)
+		if !slices.Equal(older, all) {
+			t.Errorf("limit %d: walking back saw %v, want %v", limit, older, all)
+		}
+
+		var newer []string
+		req = MessagePageRequest{After: all[0], Limit: limit}
+		for {
+			page, err := chats.ListMessages(ctx, "chat-1", req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch := slices.Clone(page.Messages)
+			slices.Reverse(batch)
+			for _, msg := range batch {
+				newer = append(newer, msg.MessageID)
+			}
+			if !page.HasMoreAfter {
+				break
+			}
+			req = MessagePageRequest{After: newer[len(newer)-1], Limit: limit}
+		}
+		if !slices.Equal(newer, all[1:]) {
+			t.Errorf("limit %d: walking forward saw %v, want %v", limit, newer, all[1:])
+		}
+	}
+}
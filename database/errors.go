@@ -0,0 +1,103 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("already exists")
+	ErrInvalid  = errors.New("invalid")
+)
+
+// entityError ties a sentinel error to the kind of record it concerns, so
+// errors.Is(err, ErrNotFound) holds while the message reads "User not found".
+type entityError struct {
+	entity string
+	err    error
+}
+
+func (e *entityError) Error() string { return e.entity + " " + e.err.Error() }
+
+func (e *entityError) Unwrap() error { return e.err }
+
+func notFound(entity string) error { return &entityError{entity: entity, err: ErrNotFound} }
+
+func conflict(entity string) error { return &entityError{entity: entity, err: ErrConflict} }
+
+// validationError is an ErrInvalid carrying a client-facing message.
+type validationError struct {
+	msg string
+}
+
+func (e *validationError) Error() string { return e.msg }
+
+func (e *validationError) Unwrap() error { return ErrInvalid }
+
+func invalid(format string, args ...any) error {
+	return &validationError{msg: fmt.Sprintf(format, args...)}
+}
+
+// transitionError is an ErrConflict for an order status change that the
+// lifecycle does not allow.
+type transitionError struct {
+	from OrderStatus
+	to   OrderStatus
+}
+
+func (e *transitionError) Error() string {
+	return fmt.Sprintf("Cannot change order status from %s to %s", e.from, e.to)
+}
+
+func (e *transitionError) Unwrap() error { return ErrConflict }
+
+// deleteSentError is an ErrConflict for deleting an order that has left
+// NotSent. Its status history must be kept, so it can only be cancelled.
+type deleteSentError struct {
+	status OrderStatus
+}
+
+func (e *deleteSentError) Error() string {
+	return fmt.Sprintf("Cannot delete an order that is %s; cancel it instead", e.status)
+}
+
+func (e *deleteSentError) Unwrap() error { return ErrConflict }
+
+// mapError translates driver errors into the package's typed errors so that
+// no SQL text reaches callers: unique violations become ErrConflict, and
+// foreign key and check violations become ErrInvalid.
+func mapError(err error, entity string) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		return conflict(entity)
+	case "23503": // foreign_key_violation
+		if strings.Contains(pqErr.Detail, "still referenced") {
+			return invalid("%s is still in use", entity)
+		}
+		return invalid("%s refers to a record that does not exist", entity)
+	case "23514", "23502": // check_violation, not_null_violation
+		return invalid("%s has a missing or invalid value", entity)
+	}
+	return err
+}
+
+// rowsAffectedOrNotFound turns an UPDATE or DELETE that matched nothing into
+// a not found error.
+func rowsAffectedOrNotFound(result interface{ RowsAffected() (int64, error) }, entity string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return notFound(entity)
+	}
+	return nil
+}
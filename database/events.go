@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ChatEventType string
+
+const (
+	MessageCreated ChatEventType = "message.created"
+	MessageUpdated ChatEventType = "message.updated"
+	MessageDeleted ChatEventType = "message.deleted"
+	MessagesRead   ChatEventType = "messages.read"
+	MemberAdded    ChatEventType = "member.added"
+	MemberRemoved  ChatEventType = "member.removed"
+	ChatDeleted    ChatEventType = "chat.deleted"
+)
+
+// ChatEvent announces a change to a chat's messages or members. Message is
+// filled in by the receiver; it is not sent over NOTIFY, whose payload is
+// capped at 8000 bytes.
+type ChatEvent struct {
+	Type      ChatEventType `json:"type"`
+	ChatID    string        `json:"chat_id"`
+	MessageID string        `json:"message_id,omitempty"`
+	UserID    string        `json:"user_id,omitempty"`
+	Message   *Message      `json:"message,omitempty"`
+}
+
+// EventBus fans chat events out to every server replica.
+type EventBus interface {
+	Publish(ctx context.Context, event ChatEvent) error
+	// Subscribe registers fn to receive every published event, including
+	// those published by other replicas.
+	Subscribe(fn func(ChatEvent))
+	Close() error
+}
+
+type subscribers struct {
+	mu  sync.RWMutex
+	fns []func(ChatEvent)
+}
+
+func (s *subscribers) add(fn func(ChatEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fns = append(s.fns, fn)
+}
+
+func (s *subscribers) dispatch(event ChatEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, fn := range s.fns {
+		fn(event)
+	}
+}
+
+const chatEventsChannel = "chat_events"
+
+// pgEventBus publishes with pg_notify and receives on a dedicated LISTEN
+// connection, so a message created on any pod reaches clients on all pods.
+type pgEventBus struct {
+	db       *sql.DB
+	listener *pq.Listener
+	subs     subscribers
+}
+
+func NewPostgresEventBus(db *sql.DB, connInfo string) (EventBus, error) {
+	listener := pq.NewListener(connInfo, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("chat events listener", "error", err)
+		}
+	})
+	if err := listener.Listen(chatEventsChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	bus := &pgEventBus{db: db, listener: listener}
+	go bus.run()
+	return bus, nil
+}
+
+func (b *pgEventBus) run() {
+	for notification := range b.listener.Notify {
+		// A nil notification means the connection was re-established and
+		// events sent while it was down were missed.
+		if notification == nil {
+			slog.Warn("chat events listener reconnected")
+			continue
+		}
+
+		var event ChatEvent
+		if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+			slog.Error("chat events: bad payload", "error", err)
+			continue
+		}
+		b.subs.dispatch(event)
+	}
+}
+
+func (b *pgEventBus) Publish(ctx context.Context, event ChatEvent) error {
+	event.Message = nil
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = b.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, chatEventsChannel, string(payload))
+	return err
+}
+
+func (b *pgEventBus) Subscribe(fn func(ChatEvent)) {
+	b.subs.add(fn)
+}
+
+func (b *pgEventBus) Close() error {
+	return b.listener.Close()
+}
+
+type memoryEventBus struct {
+	subs subscribers
+}
+
+// NewMemoryEventBus delivers events synchronously within one process.
+func NewMemoryEventBus() EventBus {
+	return &memoryEventBus{}
+}
+
+func (b *memoryEventBus) Publish(ctx context.Context, event ChatEvent) error {
+	event.Message = nil
+	b.subs.dispatch(event)
+	return nil
+}
+
+func (b *memoryEventBus) Subscribe(fn func(ChatEvent)) {
+	b.subs.add(fn)
+}
+
+func (b *memoryEventBus) Close() error {
+	return nil
+}
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// A key's failures are forgotten after FailureWindow without a new one.
+// Once a key reaches its threshold, each further failure locks it for
+// LockoutBase doubled per extra failure, up to MaxLockout. The failed login
+// audit keeps LoginFailureRetention of history.
+var (
+	EmailFailureThreshold = 5
+	IPFailureThreshold    = 20
+	FailureWindow         = time.Hour
+	LockoutBase           = 30 * time.Second
+	MaxLockout            = time.Hour
+	LoginFailureRetention = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrLoginLocked        = errors.New("too many failed login attempts, try again later")
+)
+
+// LockoutError is returned while a login is locked out.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string { return ErrLoginLocked.Error() }
+
+func (e *LockoutError) Unwrap() error { return ErrLoginLocked }
+
+type LoginFailureReason string
+
+const (
+	LoginUnknownEmail LoginFailureReason = "unknown_email"
+	LoginBadPassword  LoginFailureReason = "bad_password"
+	LoginLocked       LoginFailureReason = "locked"
+)
+
+// LoginFailure is one row of the failed login audit.
+type LoginFailure struct {
+	ID        int64              `json:"id"`
+	Email     string             `json:"email"`
+	IP        string             `json:"ip"`
+	Reason    LoginFailureReason `json:"reason"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type LoginFailureFilter struct {
+	Email string
+	IP    string
+	Since *time.Time
+}
+
+func emailKey(email string) string { return "email:" + NormalizeEmail(email) }
+
+func ipKey(ip string) string { return "ip:" + ip }
+
+// lockoutFor is how long a key with failures failed attempts stays locked.
+func lockoutFor(failures int, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	lockout := LockoutBase
+	for i := threshold; i < failures && lockout < MaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, MaxLockout)
+}
+
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := HashedPassword("not a real password")
+	return hash
+})
+
+// Authenticate checks a login attempt from ip. Unknown emails and wrong
+// passwords both return ErrInvalidCredentials and take the same time, so
+// callers cannot tell which it was. Locked out attempts return *LockoutError.
+func Authenticate(ctx context.Context, users UserStore, logins LoginStore, email string, password string, ip string) (User, error) {
+	keys := []string{emailKey(email), ipKey(ip)}
+
+	lockedUntil, err := logins.LockedUntil(ctx, keys)
+	if err != nil {
+		return User{}, err
+	}
+	if wait := time.Until(lockedUntil); wait > 0 {
+		if err := logins.AuditFailure(ctx, LoginFailure{Email: email, IP: ip, Reason: LoginLocked}); err != nil {
+			return User{}, err
+		}
+		return User{}, &LockoutError{RetryAfter: wait}
+	}
+
+	user, err := users.GetByEmail(ctx, email)
+	reason := LoginBadPassword
+	if errors.Is(err, ErrNotFound) {
+		CheckPasswordHash(password, dummyHash())
+		reason = LoginUnknownEmail
+	} else if err != nil {
+		return User{}, err
+	} else if CheckPasswordHash(password, user.Password) {
+		return user, logins.Reset(ctx, emailKey(email))
+	}
+
+	if err := recordLoginFailure(ctx, logins, LoginFailure{Email: email, IP: ip, Reason: reason}); err != nil {
+		return User{}, err
+	}
+	return User{}, ErrInvalidCredentials
+}
+
+func recordLoginFailure(ctx context.Context, logins LoginStore, failure LoginFailure) error {
+	if err := logins.AuditFailure(ctx, failure); err != nil {
+		return err
+	}
+
+	thresholds := map[string]int{
+		emailKey(failure.Email): EmailFailureThreshold,
+		ipKey(failure.IP):       IPFailureThreshold,
+	}
+	for key, threshold := range thresholds {
+		failures, err := logins.AddFailure(ctx, key, FailureWindow)
+		if err != nil {
+			return err
+		}
+		if lockout := lockoutFor(failures, threshold); lockout > 0 {
+			if err := logins.Lock(ctx, key, time.Now().Add(lockout)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var loginFailureList = listSpec[LoginFailure]{
+	selectSQL: `SELECT id, email, ip, reason, created_at FROM login_failures`,
+	idColumn:  "id",
+	idCast:    "bigint",
+	id:        func(f LoginFailure) string { return strconv.FormatInt(f.ID, 10) },
+	sorts: map[string]sortField[LoginFailure]{
+		"created_at": {
+			column: "created_at", cast: "timestamptz",
+			value: func(f LoginFailure) string { return f.CreatedAt.Format(time.RFC3339Nano) },
+			less:  func(a, b LoginFailure) bool { return a.CreatedAt.Before(b.CreatedAt) },
+		},
+	},
+	defaultSort: "created_at",
+}
+
+func (filter LoginFailureFilter) matches(failure LoginFailure) bool {
+	return (filter.Email == "" || strings.EqualFold(failure.Email, filter.Email)) &&
+		(filter.IP == "" || failure.IP == filter.IP) &&
+		(filter.Since == nil || !failure.CreatedAt.Before(*filter.Since))
+}
+
+type pgLoginStore struct {
+	db *sql.DB
+}
+
+func NewPostgresLoginStore(db *sql.DB) LoginStore {
+	return &pgLoginStore{db: db}
+}
+
+func (s *pgLoginStore) LockedUntil(ctx context.Context, keys []string) (time.Time, error) {
+	defer observe("logins", "LockedUntil", time.Now())
+	var until sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT MAX(locked_until) FROM login_throttle WHERE key = ANY($1)`, pq.Array(keys)).Scan(&until)
+	return until.Time, err
+}
+
+func (s *pgLoginStore) AddFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	defer observe("logins", "AddFailure", time.Now())
+	query := `INSERT INTO login_throttle (key, failures, last_failure_at) VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_throttle.last_failure_at < NOW() - make_interval(secs => $2) THEN 1
+				ELSE login_throttle.failures + 1
+			END,
+			last_failure_at = NOW()
+		RETURNING failures`
+	var failures int
+	err := s.db.QueryRowContext(ctx, query, key, window.Seconds()).Scan(&failures)
+	return failures, err
+}
+
+func (s *pgLoginStore) Lock(ctx context.Context, key string, until time.Time) error {
+	defer observe("logins", "Lock", time.Now())
+	_, err := s.db.ExecContext(ctx, `UPDATE login_throttle SET locked_until = $2 WHERE key = $1`, key, until)
+	return err
+}
+
+func (s *pgLoginStore) Reset(ctx context.Context, key string) error {
+	defer observe("logins", "Reset", time.Now())
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_throttle WHERE key = $1`, key)
+	return err
+}
+
+func (s *pgLoginStore) Prune(ctx context.Context, window time.Duration, retention time.Duration) error {
+	defer observe("logins", "Prune", time.Now())
+	query := `DELETE FROM login_throttle
+		WHERE last_failure_at < NOW() - make_interval(secs => $1)
+		AND (locked_until IS NULL OR locked_until < NOW())`
+	if _, err := s.db.ExecContext(ctx, query, window.Seconds()); err != nil {
+		return err
+	}
+	query = `DELETE FROM login_failures WHERE created_at < NOW() - make_interval(secs => $1)`
+	_, err := s.db.ExecContext(ctx, query, retention.Seconds())
+	return err
+}
+
+func (s *pgLoginStore) AuditFailure(ctx context.Context, failure LoginFailure) error {
+	defer observe("logins", "AuditFailure", time.Now())
+	query := `INSERT INTO login_failures (email, ip, reason, created_at) VALUES ($1, $2, $3, NOW())`
+	_, err := s.db.ExecContext(ctx, query, failure.Email, failure.IP, failure.Reason)
+	return err
+}
+
+func scanLoginFailure(row scanner) (LoginFailure, error) {
+	var failure LoginFailure
+	err := row.Scan(&failure.ID, &failure.Email, &failure.IP, &failure.Reason, &failure.CreatedAt)
+	return failure, err
+}
+
+func (s *pgLoginStore) ListFailures(ctx context.Context, filter LoginFailureFilter, page PageRequest) (Page[LoginFailure], error) {
+	defer observe("logins", "ListFailures", time.Now())
+	var b queryBuilder
+	if filter.Email != "" {
+		b.where("lower(email) = lower(?)", filter.Email)
+	}
+	if filter.IP != "" {
+		b.where("ip = ?", filter.IP)
+	}
+	if filter.Since != nil {
+		b.where("created_at >= ?", *filter.Since)
+	}
+	return queryPage(ctx, s.db, loginFailureList, b, page, scanLoginFailure)
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLockoutFor(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 4, want: 0},
+		{failures: 5, want: LockoutBase},
+		{failures: 6, want: 2 * LockoutBase},
+		{failures: 8, want: 8 * LockoutBase},
+		{failures: 12, want: MaxLockout},
+		{failures: 1000, want: MaxLockout},
+	}
+	for _, tt := range tests {
+		if got := lockoutFor(tt.failures, 5); got != tt.want {
+			t.Errorf("lockoutFor(%d, 5) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+type loginAttempt struct {
+	email    string
+	password string
+	ip       string
+}
+
+func TestAuthenticateLockout(t *testing.T) {
+	const (
+		email    = "ada@example.com"
+		password = "correct horse"
+	)
+	wrong := loginAttempt{email: email, password: "wrong", ip: "10.0.0.1"}
+	repeat := func(attempt loginAttempt, n int) []loginAttempt {
+		attempts := make([]loginAttempt, n)
+		for i := range attempts {
+			attempts[i] = attempt
+		}
+		return attempts
+	}
+	// Locked attempts are not counted, so spread IP failures over emails
+	// that stay below their own threshold.
+	spray := make([]loginAttempt, IPFailureThreshold)
+	for i := range spray {
+		spray[i] = loginAttempt{email: fmt.Sprintf("guess%d@example.com", i), password: "wrong", ip: "10.0.0.9"}
+	}
+
+	tests := []struct {
+		name    string
+		before  []loginAttempt
+		attempt loginAttempt
+		wantErr error
+	}{
+		{
+			name:    "correct password",
+			attempt: loginAttempt{email: email, password: password, ip: "10.0.0.1"},
+		},
+		{
+			name:    "wrong password",
+			attempt: wrong,
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "unknown email",
+			attempt: loginAttempt{email: "nobody@example.com", password: password, ip: "10.0.0.1"},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "below the email threshold",
+			before:  repeat(wrong, EmailFailureThreshold-1),
+			attempt: loginAttempt{email: email, password: password, ip: "10.0.0.1"},
+		},
+		{
+			name:    "email lookup is case insensitive",
+			attempt: loginAttempt{email: " ADA@Example.com", password: password, ip: "10.0.0.1"},
+		},
+		{
+			name:    "email locked even with the right password",
+			before:  repeat(wrong, EmailFailureThreshold),
+			attempt: loginAttempt{email: email, password: password, ip: "10.0.0.1"},
+			wantErr: ErrLoginLocked,
+		},
+		{
+			name:    "email locked from another ip",
+			before:  repeat(wrong, EmailFailureThreshold),
+			attempt: loginAttempt{email: email, password: password, ip: "10.0.0.2"},
+			wantErr: ErrLoginLocked,
+		},
+		{
+			name:    "email lock is case insensitive",
+			before:  repeat(wrong, EmailFailureThreshold),
+			attempt: loginAttempt{email: "ADA@example.com", password: password, ip: "10.0.0.2"},
+			wantErr: ErrLoginLocked,
+		},
+		{
+			name:    "other emails are not locked",
+			before:  repeat(wrong, EmailFailureThreshold),
+			attempt: loginAttempt{email: "grace@example.com", password: password, ip: "10.0.0.2"},
+		},
+		{
+			name:    "success resets the email count",
+			before:  append(append(repeat(wrong, EmailFailureThreshold-1), loginAttempt{email: email, password: password, ip: "10.0.0.1"}), wrong),
+			attempt: loginAttempt{email: email, password: password, ip: "10.0.0.1"},
+		},
+		{
+			name:    "ip locked across emails",
+			before:  spray,
+			attempt: loginAttempt{email: email, password: password, ip: "10.0.0.9"},
+			wantErr: ErrLoginLocked,
+		},
+		{
+			name:    "ip lock spares other ips",
+			before:  spray,
+			attempt: loginAttempt{email: email, password: password, ip: "10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores := NewMemoryStores()
+			for _, user := range []User{{ID: "user-1", Name: "Ada", Email: email}, {ID: "user-2", Name: "Grace", Email: "grace@example.com"}} {
+				hash, err := HashedPassword(password)
+				if err != nil {
+					t.Fatal(err)
+				}
+				user.Password = hash
+				if err := stores.Users.Create(ctx, user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, a := range tt.before {
+				Authenticate(ctx, stores.Users, stores.Logins, a.email, a.password, a.ip)
+			}
+
+			_, err := Authenticate(ctx, stores.Users, stores.Logins, tt.attempt.email, tt.attempt.password, tt.attempt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate error = %v, want %v", err, tt.wantErr)
+			}
+			var lockout *LockoutError
+			if errors.As(err, &lockout) && (lockout.RetryAfter <= 0 || lockout.RetryAfter > LockoutBase) {
+				t.Errorf("RetryAfter = %s, want within (0, %s]", lockout.RetryAfter, LockoutBase)
+			}
+		})
+	}
+}
+
+func TestPruneLogins(t *testing.T) {
+	ctx := context.Background()
+	logins := NewMemoryStores().Logins
+	for _, key := range []string{"stale", "locked", "recent"} {
+		if _, err := logins.AddFailure(ctx, key, FailureWindow); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := logins.Lock(ctx, "locked", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := logins.AuditFailure(ctx, LoginFailure{Email: "ada@example.com", IP: "10.0.0.1", Reason: LoginBadPassword}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := logins.AddFailure(ctx, "recent", FailureWindow); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := logins.Prune(ctx, 5*time.Millisecond, 5*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]int{"stale": 1, "locked": 2, "recent": 3} {
+		// A pruned key starts counting again from one.
+		failures, err := logins.AddFailure(ctx, key, FailureWindow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if failures != want {
+			t.Errorf("%s: failures after prune = %d, want %d", key, failures, want)
+		}
+	}
+	page, err := logins.ListFailures(ctx, LoginFailureFilter{}, PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 0 {
+		t.Errorf("audit rows after prune = %d, want 0", len(page.Items))
+	}
+}
@@ -0,0 +1,420 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryChatStore struct {
+	mu        sync.RWMutex
+	chats     map[string]Chat
+	messages  map[string]Message
+	reads     map[string]map[string]ReadReceipt // chat ID, then user ID
+	edits     map[string][]MessageEdit          // message ID
+	reactions map[string][]memoryReaction       // message ID, oldest first
+}
+
+type memoryReaction struct {
+	userID string
+	emoji  string
+}
+
+func NewMemoryChatStore() ChatStore {
+	return &memoryChatStore{
+		chats:     make(map[string]Chat),
+		messages:  make(map[string]Message),
+		reads:     make(map[string]map[string]ReadReceipt),
+		edits:     make(map[string][]MessageEdit),
+		reactions: make(map[string][]memoryReaction),
+	}
+}
+
+func (s *memoryChatStore) CreateChat(ctx context.Context, chat Chat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chats[chat.ChatID]; ok {
+		return conflict("Chat")
+	}
+	chat.CreatedAt = time.Now()
+	chat.UpdatedAt = chat.CreatedAt
+	s.chats[chat.ChatID] = chat
+	return nil
+}
+
+func (s *memoryChatStore) GetChat(ctx context.Context, chatID string) (Chat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, ok := s.chats[chatID]
+	if !ok {
+		return Chat{}, notFound("Chat")
+	}
+	return chat, nil
+}
+
+func (s *memoryChatStore) ListChats(ctx context.Context, filter ChatFilter, page PageRequest) (Page[Chat], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var chats []Chat
+	for _, chat := range s.chats {
+		if filter.Member == "" || slices.Contains(chat.Users, filter.Member) {
+			chats = append(chats, chat)
+		}
+	}
+	return memoryPage(chats, chatList, page)
+}
+
+func (s *memoryChatStore) ListChatSummaries(ctx context.Context, filter ChatFilter, page PageRequest) (Page[ChatSummary], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := make(map[string]Message)
+	for _, msg := range s.messages {
+		last, ok := latest[msg.Chat]
+		if !ok || msg.CreatedAt.After(last.CreatedAt) || (msg.CreatedAt.Equal(last.CreatedAt) && msg.MessageID > last.MessageID) {
+			latest[msg.Chat] = msg
+		}
+	}
+
+	var summaries []ChatSummary
+	for _, chat := range s.chats {
+		if filter.Member != "" && !slices.Contains(chat.Users, filter.Member) {
+			continue
+		}
+		summary := ChatSummary{Chat: chat, LastActivityAt: chat.CreatedAt}
+		if msg, ok := latest[chat.ChatID]; ok {
+			msg.Text = previewText(msg.Text)
+			summary.LastMessage = &msg
+			summary.LastActivityAt = msg.CreatedAt
+		}
+		summaries = append(summaries, summary)
+	}
+	return memoryPage(summaries, chatSummaryList, page)
+}
+
+func (s *memoryChatStore) SetMember(ctx context.Context, chatID string, userID string, role ChatRole) error {
+	if role != ChatAdmin && role != ChatMember {
+		return invalid("Role must be %s or %s", ChatAdmin, ChatMember)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[chatID]
+	if !ok {
+		return notFound("Chat")
+	}
+	if chat.Owner == userID {
+		return errOwnerRole
+	}
+	if !slices.Contains(chat.Users, userID) {
+		chat.Users = append(slices.Clone(chat.Users), userID)
+	}
+	chat.Admins = slices.DeleteFunc(slices.Clone(chat.Admins), func(id string) bool { return id == userID })
+	if role == ChatAdmin {
+		chat.Admins = append(chat.Admins, userID)
+	}
+	chat.UpdatedAt = time.Now()
+	s.chats[chatID] = chat
+	return nil
+}
+
+func (s *memoryChatStore) RemoveMember(ctx context.Context, chatID string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[chatID]
+	if !ok || !slices.Contains(chat.Users, userID) {
+		return notFound("Chat member")
+	}
+	isUser := func(id string) bool { return id == userID }
+	chat.Users = slices.DeleteFunc(slices.Clone(chat.Users), isUser)
+	chat.Admins = slices.DeleteFunc(slices.Clone(chat.Admins), isUser)
+	chat.UpdatedAt = time.Now()
+	s.chats[chatID] = chat
+	delete(s.reads[chatID], userID)
+	return nil
+}
+
+func (s *memoryChatStore) MarkRead(ctx context.Context, chatID string, userID string, messageID string) (ReadReceipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[chatID]
+	if !ok || !slices.Contains(chat.Users, userID) {
+		return ReadReceipt{UserID: userID}, notFound("Chat member")
+	}
+	current, read := s.reads[chatID][userID]
+	if !read {
+		current = ReadReceipt{UserID: userID}
+	}
+
+	var target *Message
+	for _, msg := range s.messages {
+		if msg.Chat != chatID || (messageID != "" && msg.MessageID != messageID) {
+			continue
+		}
+		if target == nil || !(ReadReceipt{LastReadMessageID: target.MessageID, LastReadAt: target.CreatedAt}).HasRead(msg) {
+			target = &msg
+		}
+	}
+	if target == nil {
+		if messageID != "" {
+			return current, notFound("Message")
+		}
+		return current, nil
+	}
+	if read && current.HasRead(*target) {
+		return current, nil
+	}
+
+	if s.reads[chatID] == nil {
+		s.reads[chatID] = make(map[string]ReadReceipt)
+	}
+	current = ReadReceipt{UserID: userID, LastReadMessageID: target.MessageID, LastReadAt: target.CreatedAt}
+	s.reads[chatID][userID] = current
+	return current, nil
+}
+
+func (s *memoryChatStore) UnreadCounts(ctx context.Context, userID string, chatIDs []string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, chatID := range chatIDs {
+		if chat, ok := s.chats[chatID]; ok && slices.Contains(chat.Users, userID) {
+			counts[chatID] = 0
+		}
+	}
+	for _, msg := range s.messages {
+		if _, ok := counts[msg.Chat]; !ok || msg.Sender == userID || msg.Deleted() {
+			continue
+		}
+		if receipt, ok := s.reads[msg.Chat][userID]; !ok || !receipt.HasRead(msg) {
+			counts[msg.Chat]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *memoryChatStore) ReadReceipts(ctx context.Context, chatID string) ([]ReadReceipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var receipts []ReadReceipt
+	for _, receipt := range s.reads[chatID] {
+		receipts = append(receipts, receipt)
+	}
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].UserID < receipts[j].UserID })
+	return receipts, nil
+}
+
+func (s *memoryChatStore) DeleteChat(ctx context.Context, chatID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chats[chatID]; !ok {
+		return notFound("Chat")
+	}
+	delete(s.chats, chatID)
+	delete(s.reads, chatID)
+	for id, msg := range s.messages {
+		if msg.Chat == chatID {
+			delete(s.messages, id)
+			delete(s.edits, id)
+			delete(s.reactions, id)
+		}
+	}
+	return nil
+}
+
+func (s *memoryChatStore) IsMember(ctx context.Context, chatID string, userID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, ok := s.chats[chatID]
+	if !ok {
+		return false, notFound("Chat")
+	}
+	return slices.Contains(chat.Users, userID), nil
+}
+
+func (s *memoryChatStore) CreateMessage(ctx context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.chats[msg.Chat]; !ok {
+		return notFound("Chat")
+	}
+	if _, ok := s.messages[msg.MessageID]; ok {
+		return conflict("Message")
+	}
+	msg.CreatedAt = time.Now()
+	s.messages[msg.MessageID] = msg
+	return nil
+}
+
+func (s *memoryChatStore) GetMessage(ctx context.Context, messageID string) (Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msg, ok := s.messages[messageID]
+	if !ok {
+		return Message{}, notFound("Message")
+	}
+	return s.withReactions(msg), nil
+}
+
+// withReactions fills in msg's reaction counts. The caller holds s.mu.
+func (s *memoryChatStore) withReactions(msg Message) Message {
+	msg.Reactions = []ReactionCount{}
+	index := make(map[string]int)
+	for _, reaction := range s.reactions[msg.MessageID] {
+		i, ok := index[reaction.emoji]
+		if !ok {
+			i = len(msg.Reactions)
+			index[reaction.emoji] = i
+			msg.Reactions = append(msg.Reactions, ReactionCount{Emoji: reaction.emoji})
+		}
+		msg.Reactions[i].Count++
+	}
+	return msg
+}
+
+func (s *memoryChatStore) ListMessages(ctx context.Context, chatID string, req MessagePageRequest) (MessagePage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if req.Before != "" && req.After != "" {
+		return MessagePage{}, invalid("Only one of before and after may be set")
+	}
+	limit := pageLimit(req.Limit)
+
+	var messages []Message
+	for _, msg := range s.messages {
+		if msg.Chat == chatID {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		a, b := messages[i], messages[j]
+		return a.CreatedAt.Before(b.CreatedAt) || (a.CreatedAt.Equal(b.CreatedAt) && a.MessageID < b.MessageID)
+	})
+
+	// Order the candidates nearest the cursor first, as the SQL query does.
+	cursorID := req.Before + req.After
+	if cursorID != "" {
+		i := slices.IndexFunc(messages, func(msg Message) bool { return msg.MessageID == cursorID })
+		if i < 0 {
+			return MessagePage{}, notFound("Message")
+		}
+		if req.After != "" {
+			messages = messages[i+1:]
+		} else {
+			messages = messages[:i]
+		}
+	}
+	if req.After == "" {
+		slices.Reverse(messages)
+	}
+
+	messages = messages[:min(len(messages), limit+1)]
+	for i, msg := range messages {
+		messages[i] = s.withReactions(msg)
+	}
+	return newMessagePage(messages, req, limit), nil
+}
+
+func (s *memoryChatStore) EditMessage(ctx context.Context, messageID string, text string, media []string) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[messageID]
+	if !ok {
+		return Message{}, notFound("Message")
+	}
+	if msg.Deleted() {
+		return Message{}, errMessageDeleted
+	}
+
+	now := time.Now()
+	old := MessageEdit{Text: msg.Text, Media: msg.Media, EditedAt: now}
+	if old.Media == nil {
+		old.Media = []string{}
+	}
+	s.edits[messageID] = append(s.edits[messageID], old)
+	msg.Text = text
+	msg.Media = media
+	msg.EditedAt = &now
+	s.messages[messageID] = msg
+	return s.withReactions(msg), nil
+}
+
+func (s *memoryChatStore) MessageEdits(ctx context.Context, messageID string) ([]MessageEdit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.messages[messageID]; !ok {
+		return nil, notFound("Message")
+	}
+	return append([]MessageEdit{}, s.edits[messageID]...), nil
+}
+
+func (s *memoryChatStore) DeleteMessage(ctx context.Context, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg, ok := s.messages[messageID]
+	if !ok {
+		return notFound("Message")
+	}
+	if msg.Deleted() {
+		return nil
+	}
+	now := time.Now()
+	msg.Text = ""
+	msg.Media = []string{}
+	msg.DeletedAt = &now
+	s.messages[messageID] = msg
+	delete(s.edits, messageID)
+	delete(s.reactions, messageID)
+	return nil
+}
+
+func (s *memoryChatStore) AddReaction(ctx context.Context, messageID string, userID string, emoji string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !ValidReaction(emoji) {
+		return errReaction
+	}
+	msg, ok := s.messages[messageID]
+	if !ok {
+		return notFound("Message")
+	}
+	if msg.Deleted() {
+		return errMessageDeleted
+	}
+	reaction := memoryReaction{userID: userID, emoji: emoji}
+	if !slices.Contains(s.reactions[messageID], reaction) {
+		s.reactions[messageID] = append(s.reactions[messageID], reaction)
+	}
+	return nil
+}
+
+func (s *memoryChatStore) RemoveReaction(ctx context.Context, messageID string, userID string, emoji string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reactions := s.reactions[messageID]
+	i := slices.Index(reactions, memoryReaction{userID: userID, emoji: emoji})
+	if i < 0 {
+		return notFound("Reaction")
+	}
+	s.reactions[messageID] = slices.Delete(slices.Clone(reactions), i, i+1)
+	return nil
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type loginThrottle struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+type memoryLoginStore struct {
+	mu       sync.Mutex
+	throttle map[string]loginThrottle
+	failures []LoginFailure
+	lastID   int64
+}
+
+func NewMemoryLoginStore() LoginStore {
+	return &memoryLoginStore{throttle: make(map[string]loginThrottle)}
+}
+
+func (s *memoryLoginStore) LockedUntil(ctx context.Context, keys []string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var until time.Time
+	for _, key := range keys {
+		if t := s.throttle[key].lockedUntil; t.After(until) {
+			until = t
+		}
+	}
+	return until, nil
+}
+
+func (s *memoryLoginStore) AddFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.throttle[key]
+	if time.Since(t.lastFailureAt) > window {
+		t.failures = 0
+	}
+	t.failures++
+	t.lastFailureAt = time.Now()
+	s.throttle[key] = t
+	return t.failures, nil
+}
+
+func (s *memoryLoginStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.throttle[key]
+	t.lockedUntil = until
+	s.throttle[key] = t
+	return nil
+}
+
+func (s *memoryLoginStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.throttle, key)
+	return nil
+}
+
+func (s *memoryLoginStore) Prune(ctx context.Context, window time.Duration, retention time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, t := range s.throttle {
+		if now.Sub(t.lastFailureAt) > window && !t.lockedUntil.After(now) {
+			delete(s.throttle, key)
+		}
+	}
+	s.failures = slices.DeleteFunc(s.failures, func(failure LoginFailure) bool {
+		return now.Sub(failure.CreatedAt) > retention
+	})
+	return nil
+}
+
+func (s *memoryLoginStore) AuditFailure(ctx context.Context, failure LoginFailure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	failure.ID = s.lastID
+	failure.CreatedAt = time.Now()
+	s.failures = append(s.failures, failure)
+	return nil
+}
+
+func (s *memoryLoginStore) ListFailures(ctx context.Context, filter LoginFailureFilter, page PageRequest) (Page[LoginFailure], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var failures []LoginFailure
+	for _, failure := range s.failures {
+		if filter.matches(failure) {
+			failures = append(failures, failure)
+		}
+	}
+	return memoryPage(failures, loginFailureList, page)
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryOrderStore struct {
+	mu       sync.RWMutex
+	orders   map[int]Order
+	nextID   int
+	products ProductStore
+	history  map[int][]OrderStatusChange
+}
+
+// NewMemoryOrderStore prices new orders from products.
+func NewMemoryOrderStore(products ProductStore) OrderStore {
+	return &memoryOrderStore{
+		orders:   make(map[int]Order),
+		nextID:   1,
+		products: products,
+		history:  make(map[int][]OrderStatusChange),
+	}
+}
+
+func (s *memoryOrderStore) Create(ctx context.Context, order Order) (Order, error) {
+	items, err := normalizeItems(order.Items)
+	if err != nil {
+		return order, err
+	}
+
+	order.Total, err = priceItems(items, func(upc string) (Product, bool) {
+		product, err := s.products.Get(ctx, upc)
+		return product, err == nil
+	})
+	if err != nil {
+		return order, err
+	}
+	order.Items = items
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order.OrderNumber = s.nextID
+	s.nextID++
+	order.CreatedAt = time.Now().Format(time.RFC3339)
+	order.UpdatedAt = order.CreatedAt
+	s.orders[order.OrderNumber] = order
+	return order, nil
+}
+
+func (s *memoryOrderStore) Get(ctx context.Context, orderNumber int) (Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, ok := s.orders[orderNumber]
+	if !ok {
+		return Order{}, notFound("Order")
+	}
+	return order, nil
+}
+
+func (s *memoryOrderStore) List(ctx context.Context, filter OrderFilter, page PageRequest) (Page[Order], error) {
+	if err := filter.validate(); err != nil {
+		return Page[Order]{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var orders []Order
+	for _, order := range s.orders {
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		if filter.User != "" && order.User != filter.User {
+			continue
+		}
+		created, _ := time.Parse(time.RFC3339, order.CreatedAt)
+		if filter.CreatedAfter != nil && created.Before(*filter.CreatedAfter) {
+			continue
+		}
+		if filter.CreatedBefore != nil && !created.Before(*filter.CreatedBefore) {
+			continue
+		}
+		orders = append(orders, order)
+	}
+	return memoryPage(orders, orderList, page)
+}
+
+func (s *memoryOrderStore) Update(ctx context.Context, order Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.orders[order.OrderNumber]
+	if !ok {
+		return notFound("Order")
+	}
+	existing.User = order.User
+	existing.UpdatedAt = time.Now().Format(time.RFC3339)
+	s.orders[order.OrderNumber] = existing
+	return nil
+}
+
+func (s *memoryOrderStore) UpdateStatus(ctx context.Context, orderNumber int, status OrderStatus, changedBy string) (Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderNumber]
+	if !ok {
+		return Order{}, notFound("Order")
+	}
+	if !order.Status.CanTransitionTo(status) {
+		return Order{}, &transitionError{from: order.Status, to: status}
+	}
+
+	s.history[orderNumber] = append(s.history[orderNumber], OrderStatusChange{
+		OrderNumber: orderNumber,
+		FromStatus:  order.Status,
+		ToStatus:    status,
+		ChangedBy:   changedBy,
+		ChangedAt:   time.Now(),
+	})
+	order.Status = status
+	order.UpdatedAt = time.Now().Format(time.RFC3339)
+	s.orders[orderNumber] = order
+	return order, nil
+}
+
+func (s *memoryOrderStore) History(ctx context.Context, orderNumber int) ([]OrderStatusChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.orders[orderNumber]; !ok {
+		return nil, notFound("Order")
+	}
+	return append([]OrderStatusChange(nil), s.history[orderNumber]...), nil
+}
+
+func (s *memoryOrderStore) Delete(ctx context.Context, orderNumber int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderNumber]
+	if !ok {
+		return notFound("Order")
+	}
+	if order.Status != NotSent {
+		return &deleteSentError{status: order.Status}
+	}
+	delete(s.orders, orderNumber)
+	delete(s.history, orderNumber)
+	return nil
+}
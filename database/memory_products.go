@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryProductStore struct {
+	mu       sync.RWMutex
+	products map[string]Product
+}
+
+func NewMemoryProductStore() ProductStore {
+	return &memoryProductStore{products: make(map[string]Product)}
+}
+
+func (s *memoryProductStore) Create(ctx context.Context, product Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[product.UPC]; ok {
+		return conflict("Product")
+	}
+	product.CreatedAt = time.Now().Format(time.RFC3339)
+	product.UpdatedAt = product.CreatedAt
+	s.products[product.UPC] = product
+	return nil
+}
+
+func (s *memoryProductStore) Get(ctx context.Context, upc string) (Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	product, ok := s.products[upc]
+	if !ok {
+		return Product{}, notFound("Product")
+	}
+	return product, nil
+}
+
+func (s *memoryProductStore) List(ctx context.Context, filter ProductFilter, page PageRequest) (Page[Product], error) {
+	if _, err := filter.priceRange(); err != nil {
+		return Page[Product]{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var products []Product
+	for _, product := range s.products {
+		if filter.matches(product) {
+			products = append(products, product)
+		}
+	}
+	return memoryPage(products, productList, page)
+}
+
+func (s *memoryProductStore) Update(ctx context.Context, product Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.products[product.UPC]
+	if !ok {
+		return notFound("Product")
+	}
+	existing.Name = product.Name
+	existing.Description = product.Description
+	existing.Price = product.Price
+	existing.UpdatedAt = time.Now().Format(time.RFC3339)
+	s.products[product.UPC] = existing
+	return nil
+}
+
+func (s *memoryProductStore) Delete(ctx context.Context, upc string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[upc]; !ok {
+		return notFound("Product")
+	}
+	delete(s.products, upc)
+	return nil
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	limit     RateLimit
+}
+
+// full reports whether b has refilled by now under its own limit.
+func (b bucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.updatedAt).Seconds()*b.limit.Rate >= float64(b.limit.Burst)
+}
+
+// pruneAt is the bucket count above which full buckets are forgotten; a
+// full bucket behaves exactly like a missing one.
+const pruneAt = 10000
+
+type memoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+}
+
+// NewMemoryRateLimiter keeps buckets in process, so each replica enforces
+// its own limits.
+func NewMemoryRateLimiter() RateLimiter {
+	return &memoryRateLimiter{buckets: make(map[string]bucket)}
+}
+
+func (l *memoryRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (RateDecision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if len(l.buckets) > pruneAt {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = bucket{tokens: float64(limit.Burst), updatedAt: now}
+	}
+	b.tokens = min(float64(limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*limit.Rate)
+	b.updatedAt = now
+	b.limit = limit
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	l.buckets[key] = b
+	return decide(limit, b.tokens, allowed), nil
+}
+
+func (l *memoryRateLimiter) Prune(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(time.Now())
+	return nil
+}
+
+func (l *memoryRateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]RefreshToken
+	resets map[string]PasswordReset
+	// verifications is keyed by token hash; sentAt by user ID.
+	verifications map[string]EmailVerification
+	sentAt        map[string]time.Time
+}
+
+func NewMemoryTokenStore() TokenStore {
+	return &memoryTokenStore{
+		tokens: make(map[string]RefreshToken),
+		resets: make(map[string]PasswordReset),
+
+		verifications: make(map[string]EmailVerification),
+		sentAt:        make(map[string]time.Time),
+	}
+}
+
+func (s *memoryTokenStore) CreateRefreshToken(ctx context.Context, token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.TokenID] = token
+	return nil
+}
+
+func (s *memoryTokenStore) revokeFamily(familyID string) {
+	now := time.Now()
+	for id, token := range s.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.tokens[id] = token
+		}
+	}
+}
+
+func (s *memoryTokenStore) RotateRefreshToken(ctx context.Context, tokenID string, userID string, next RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[tokenID]
+	if !ok || token.UserID != userID || token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return ErrRefreshTokenInvalid
+	}
+	if token.UsedAt != nil {
+		s.revokeFamily(token.FamilyID)
+		return ErrRefreshTokenReused
+	}
+	if _, ok := s.tokens[next.TokenID]; ok {
+		return conflict("Refresh token")
+	}
+
+	now := time.Now()
+	token.UsedAt = &now
+	s.tokens[tokenID] = token
+	next.FamilyID = token.FamilyID
+	s.tokens[next.TokenID] = next
+	return nil
+}
+
+func (s *memoryTokenStore) RevokeFamily(ctx context.Context, tokenID string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[tokenID]
+	if !ok || token.UserID != userID || token.RevokedAt != nil {
+		return ErrRefreshTokenInvalid
+	}
+	s.revokeFamily(token.FamilyID)
+	return nil
+}
+
+func (s *memoryTokenStore) RevokeUserTokens(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			s.tokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (s *memoryTokenStore) CreatePasswordReset(ctx context.Context, reset PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resets[reset.TokenHash] = reset
+	return nil
+}
+
+func (s *memoryTokenStore) ConsumePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reset, ok := s.resets[tokenHash]
+	if !ok || reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return reset, ErrResetTokenInvalid
+	}
+	now := time.Now()
+	reset.UsedAt = &now
+	s.resets[tokenHash] = reset
+	return reset, nil
+}
+
+func (s *memoryTokenStore) CreateEmailVerification(ctx context.Context, verification EmailVerification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.verifications[verification.TokenHash] = verification
+	s.sentAt[verification.UserID] = time.Now()
+	return nil
+}
+
+func (s *memoryTokenStore) ConsumeEmailVerification(ctx context.Context, tokenHash string) (EmailVerification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verification, ok := s.verifications[tokenHash]
+	if !ok || verification.UsedAt != nil || time.Now().After(verification.ExpiresAt) {
+		return verification, ErrVerificationTokenInvalid
+	}
+	now := time.Now()
+	verification.UsedAt = &now
+	s.verifications[tokenHash] = verification
+	return verification, nil
+}
+
+func (s *memoryTokenStore) LastEmailVerification(ctx context.Context, userID string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sentAt[userID], nil
+}
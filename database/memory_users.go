@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryUserStore struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+func NewMemoryUserStore() UserStore {
+	return &memoryUserStore{users: make(map[string]User)}
+}
+
+// taken reports whether another user already holds name or email.
+func (s *memoryUserStore) taken(user User) bool {
+	for id, existing := range s.users {
+		if id != user.ID && (existing.Name == user.Name || existing.Email == user.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryUserStore) Create(ctx context.Context, user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user.Email = NormalizeEmail(user.Email)
+	if _, ok := s.users[user.ID]; ok || s.taken(user) {
+		return conflict("User")
+	}
+	if user.Role == "" {
+		user.Role = RoleCustomer
+	}
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	s.users[user.ID] = user
+	return nil
+}
+
+func (s *memoryUserStore) GetByID(ctx context.Context, id string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return User{}, notFound("User")
+	}
+	return user, nil
+}
+
+func (s *memoryUserStore) GetByEmail(ctx context.Context, email string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	email = NormalizeEmail(email)
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return User{}, notFound("User")
+}
+
+func (s *memoryUserStore) List(ctx context.Context, filter UserFilter, page PageRequest) (Page[User], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var users []User
+	for _, user := range s.users {
+		if hasPrefixFold(user.Name, filter.NamePrefix) {
+			users = append(users, user)
+		}
+	}
+	return memoryPage(users, userList, page)
+}
+
+func (s *memoryUserStore) Update(ctx context.Context, user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[user.ID]
+	if !ok {
+		return notFound("User")
+	}
+	user.Email = NormalizeEmail(user.Email)
+	if s.taken(user) {
+		return conflict("User")
+	}
+	existing.Name = user.Name
+	if user.Email != existing.Email {
+		existing.Email = user.Email
+		existing.Verified = false
+	}
+	existing.Avatar = user.Avatar
+	existing.Online = user.Online
+	existing.UpdatedAt = time.Now()
+	s.users[user.ID] = existing
+	return nil
+}
+
+func (s *memoryUserStore) UpdatePassword(ctx context.Context, id string, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[id]
+	if !ok {
+		return notFound("User")
+	}
+	existing.Password = hash
+	existing.UpdatedAt = time.Now()
+	s.users[id] = existing
+	return nil
+}
+
+func (s *memoryUserStore) MarkVerified(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[id]
+	if !ok {
+		return notFound("User")
+	}
+	existing.Verified = true
+	existing.UpdatedAt = time.Now()
+	s.users[id] = existing
+	return nil
+}
+
+func (s *memoryUserStore) UpdateRole(ctx context.Context, id string, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[id]
+	if !ok {
+		return notFound("User")
+	}
+	existing.Role = role
+	existing.UpdatedAt = time.Now()
+	s.users[id] = existing
+	return nil
+}
+
+func (s *memoryUserStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return notFound("User")
+	}
+	delete(s.users, id)
+	return nil
+}
@@ -0,0 +1,445 @@
+package database
+
+// migrations is the ordered schema history. Never edit a migration that has
+// shipped; add a new one instead.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up: `
+		CREATE OR REPLACE FUNCTION update_updated_at_column()
+		RETURNS TRIGGER AS $$
+		BEGIN
+			NEW.updated_at = NOW();
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+
+		CREATE TABLE IF NOT EXISTS users (
+			id TEXT UNIQUE NOT NULL PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			email TEXT UNIQUE NOT NULL,
+			password TEXT NOT NULL,
+			avatar TEXT,
+			online BOOL DEFAULT false,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS products (
+			upc TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			description TEXT,
+			price FLOAT,
+			images TEXT[],
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS orders (
+			order_number SERIAL PRIMARY KEY,
+			status TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			products JSONB NOT NULL,
+			total FLOAT8 NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS chats (
+			chat_id TEXT PRIMARY KEY,
+			users TEXT[] NOT NULL,
+			messages TEXT[] DEFAULT '{}',
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS messages (
+			message_id TEXT PRIMARY KEY,
+			chat_id TEXT NOT NULL REFERENCES chats(chat_id) ON DELETE CASCADE,
+			sender TEXT NOT NULL,
+			text TEXT,
+			media TEXT[] DEFAULT '{}',
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+
+		DROP TRIGGER IF EXISTS update_users_updated_at ON users;
+		CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users
+		FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+		DROP TRIGGER IF EXISTS update_products_updated_at ON products;
+		CREATE TRIGGER update_products_updated_at BEFORE UPDATE ON products
+		FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+		DROP TRIGGER IF EXISTS update_orders_updated_at ON orders;
+		CREATE TRIGGER update_orders_updated_at BEFORE UPDATE ON orders
+		FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+		DROP TRIGGER IF EXISTS update_chats_updated_at ON chats;
+		CREATE TRIGGER update_chats_updated_at BEFORE UPDATE ON chats
+		FOR EACH ROW EXECUTE PROCEDURE update_updated_at_column();
+
+		-- ConnectPSQL used to add this trigger although messages has no
+		-- updated_at column, so every UPDATE on messages would fail.
+		DROP TRIGGER IF EXISTS update_messages_updated_at ON messages;`,
+		Down: `
+		DROP TABLE IF EXISTS messages;
+		DROP TABLE IF EXISTS chats;
+		DROP TABLE IF EXISTS orders;
+		DROP TABLE IF EXISTS products;
+		DROP TABLE IF EXISTS users;
+		DROP FUNCTION IF EXISTS update_updated_at_column();`,
+	},
+	{
+		Version: 2,
+		Name:    "refresh_tokens",
+		Up: `
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			token_id TEXT PRIMARY KEY,
+			family_id TEXT NOT NULL,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			expires_at TIMESTAMP NOT NULL,
+			used_at TIMESTAMP,
+			revoked_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);`,
+		Down: `
+		DROP TABLE IF EXISTS refresh_tokens;`,
+	},
+	{
+		Version: 3,
+		Name:    "user_roles",
+		Up: `
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'customer'
+			CHECK (role IN ('customer', 'staff', 'admin'));`,
+		Down: `
+		ALTER TABLE users DROP COLUMN IF EXISTS role;`,
+	},
+	{
+		Version: 4,
+		Name:    "order_items",
+		Up: `
+		CREATE TABLE IF NOT EXISTS order_items (
+			order_number INT NOT NULL REFERENCES orders(order_number) ON DELETE CASCADE,
+			upc TEXT NOT NULL REFERENCES products(upc),
+			quantity INT NOT NULL CHECK (quantity > 0),
+			unit_price FLOAT8 NOT NULL,
+			PRIMARY KEY (order_number, upc)
+		);
+
+		-- order_items.upc must name a product, so lines for deleted products
+		-- cannot be carried over. Stop rather than drop them with the column.
+		DO $$
+		DECLARE
+			orphaned TEXT;
+		BEGIN
+			SELECT string_agg(DISTINCT o.order_number::TEXT, ', ') INTO orphaned
+			FROM orders o
+			CROSS JOIN LATERAL jsonb_array_elements_text(o.products) AS item(upc)
+			WHERE NOT EXISTS (SELECT 1 FROM products p WHERE p.upc = item.upc);
+			IF orphaned IS NOT NULL THEN
+				RAISE EXCEPTION 'orders % list products that no longer exist; restore them before migrating', orphaned;
+			END IF;
+		END $$;
+
+		INSERT INTO order_items (order_number, upc, quantity, unit_price)
+		SELECT o.order_number, p.upc, COUNT(*), COALESCE(p.price, 0)
+		FROM orders o
+		CROSS JOIN LATERAL jsonb_array_elements_text(o.products) AS item(upc)
+		JOIN products p ON p.upc = item.upc
+		GROUP BY o.order_number, p.upc, p.price
+		ON CONFLICT DO NOTHING;
+
+		ALTER TABLE orders DROP COLUMN IF EXISTS products;`,
+		Down: `
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS products JSONB NOT NULL DEFAULT '[]';
+
+		UPDATE orders o SET products = (
+			SELECT COALESCE(jsonb_agg(i.upc), '[]')
+			FROM order_items i, generate_series(1, i.quantity)
+			WHERE i.order_number = o.order_number
+		);
+
+		DROP TABLE IF EXISTS order_items;`,
+	},
+	{
+		Version: 5,
+		Name:    "order_status_history",
+		Up: `
+		CREATE TABLE IF NOT EXISTS order_status_history (
+			id BIGSERIAL PRIMARY KEY,
+			order_number INT NOT NULL REFERENCES orders(order_number) ON DELETE CASCADE,
+			from_status TEXT NOT NULL,
+			to_status TEXT NOT NULL,
+			changed_by TEXT NOT NULL,
+			changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS order_status_history_order_number_idx ON order_status_history (order_number, changed_at);`,
+		Down: `
+		DROP TABLE IF EXISTS order_status_history;`,
+	},
+	{
+		Version: 6,
+		Name:    "money_minor_units",
+		Up: `
+		ALTER TABLE products ADD COLUMN IF NOT EXISTS price_minor BIGINT NOT NULL DEFAULT 0;
+		ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+		UPDATE products SET price_minor = ROUND(COALESCE(price, 0)::NUMERIC * 100)::BIGINT;
+		ALTER TABLE products DROP COLUMN IF EXISTS price;
+
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS total_minor BIGINT NOT NULL DEFAULT 0;
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+		UPDATE orders SET total_minor = ROUND(total::NUMERIC * 100)::BIGINT;
+		ALTER TABLE orders DROP COLUMN IF EXISTS total;
+
+		ALTER TABLE order_items ADD COLUMN IF NOT EXISTS unit_price_minor BIGINT NOT NULL DEFAULT 0;
+		UPDATE order_items SET unit_price_minor = ROUND(unit_price::NUMERIC * 100)::BIGINT;
+		ALTER TABLE order_items DROP COLUMN IF EXISTS unit_price;`,
+		Down: `
+		ALTER TABLE order_items ADD COLUMN IF NOT EXISTS unit_price FLOAT8 NOT NULL DEFAULT 0;
+		UPDATE order_items SET unit_price = unit_price_minor / 100.0;
+		ALTER TABLE order_items DROP COLUMN IF EXISTS unit_price_minor;
+
+		ALTER TABLE orders ADD COLUMN IF NOT EXISTS total FLOAT8 NOT NULL DEFAULT 0;
+		UPDATE orders SET total = total_minor / 100.0;
+		ALTER TABLE orders DROP COLUMN IF EXISTS total_minor;
+		ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+
+		ALTER TABLE products ADD COLUMN IF NOT EXISTS price FLOAT;
+		UPDATE products SET price = price_minor / 100.0;
+		ALTER TABLE products DROP COLUMN IF EXISTS price_minor;
+		ALTER TABLE products DROP COLUMN IF EXISTS currency;`,
+	},
+	{
+		Version: 7,
+		Name:    "password_reset_tokens",
+		Up: `
+		CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			token_hash TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS password_reset_tokens_user_id_idx ON password_reset_tokens (user_id);`,
+		Down: `
+		DROP TABLE IF EXISTS password_reset_tokens;`,
+	},
+	{
+		Version: 8,
+		Name:    "email_verification",
+		Up: `
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS verified BOOL NOT NULL DEFAULT false;
+		-- Accounts created before verification existed are grandfathered in.
+		UPDATE users SET verified = true;
+
+		CREATE TABLE IF NOT EXISTS email_verification_tokens (
+			token_hash TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS email_verification_tokens_user_id_idx ON email_verification_tokens (user_id, created_at);`,
+		Down: `
+		DROP TABLE IF EXISTS email_verification_tokens;
+		ALTER TABLE users DROP COLUMN IF EXISTS verified;`,
+	},
+	{
+		Version: 9,
+		Name:    "login_throttling",
+		Up: `
+		CREATE TABLE IF NOT EXISTS login_throttle (
+			key TEXT PRIMARY KEY,
+			failures INT NOT NULL DEFAULT 0,
+			last_failure_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			locked_until TIMESTAMPTZ
+		);
+
+		CREATE TABLE IF NOT EXISTS login_failures (
+			id BIGSERIAL PRIMARY KEY,
+			email TEXT NOT NULL,
+			ip TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS login_failures_email_idx ON login_failures (lower(email), created_at);
+		CREATE INDEX IF NOT EXISTS login_failures_ip_idx ON login_failures (ip, created_at);
+		CREATE INDEX IF NOT EXISTS login_failures_created_at_idx ON login_failures (created_at);
+
+		-- Emails are stored lowercased so lookups and lockout keys agree.
+		-- Accounts that differ only by case must be merged by hand first.
+		DO $$
+		DECLARE
+			duplicated TEXT;
+		BEGIN
+			SELECT string_agg(email, ', ') INTO duplicated
+			FROM (SELECT lower(trim(email)) AS email FROM users GROUP BY 1 HAVING COUNT(*) > 1) d;
+			IF duplicated IS NOT NULL THEN
+				RAISE EXCEPTION 'emails % belong to more than one user; merge them before migrating', duplicated;
+			END IF;
+		END $$;
+
+		UPDATE users SET email = lower(trim(email)) WHERE email <> lower(trim(email));`,
+		Down: `
+		DROP TABLE IF EXISTS login_failures;
+		DROP TABLE IF EXISTS login_throttle;`,
+	},
+	{
+		Version: 10,
+		Name:    "rate_limits",
+		Up: `
+		-- Each bucket keeps its own limit so full ones can be pruned.
+		CREATE TABLE IF NOT EXISTS rate_limits (
+			key TEXT PRIMARY KEY,
+			tokens DOUBLE PRECISION NOT NULL,
+			rate DOUBLE PRECISION NOT NULL,
+			burst DOUBLE PRECISION NOT NULL,
+			allowed BOOL NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		Down: `
+		DROP TABLE IF EXISTS rate_limits;`,
+	},
+	{
+		Version: 11,
+		Name:    "chat_roles",
+		Up: `
+		ALTER TABLE chats ADD COLUMN IF NOT EXISTS owner TEXT;
+		ALTER TABLE chats ADD COLUMN IF NOT EXISTS admins TEXT[] NOT NULL DEFAULT '{}';
+
+		-- Chats predate roles; treat the first listed user as the creator.
+		UPDATE chats SET owner = users[1] WHERE owner IS NULL;`,
+		Down: `
+		ALTER TABLE chats DROP COLUMN IF EXISTS admins;
+		ALTER TABLE chats DROP COLUMN IF EXISTS owner;`,
+	},
+	{
+		Version: 12,
+		Name:    "chat_members",
+		Up: `
+		CREATE TABLE IF NOT EXISTS chat_members (
+			chat_id TEXT NOT NULL REFERENCES chats(chat_id) ON DELETE CASCADE,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+			joined_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (chat_id, user_id)
+		);
+		CREATE INDEX IF NOT EXISTS chat_members_user_idx ON chat_members (user_id);
+
+		-- IDs in chats.users that no longer match a user are dropped.
+		INSERT INTO chat_members (chat_id, user_id, role, joined_at)
+		SELECT c.chat_id, u.user_id,
+			CASE WHEN u.user_id = c.owner THEN 'owner' WHEN u.user_id = ANY(c.admins) THEN 'admin' ELSE 'member' END,
+			c.created_at + make_interval(secs => u.position / 1000.0)
+		FROM chats c
+		CROSS JOIN LATERAL unnest(c.users) WITH ORDINALITY AS u(user_id, position)
+		WHERE EXISTS (SELECT 1 FROM users WHERE users.id = u.user_id)
+		ON CONFLICT DO NOTHING;
+
+		-- If the owner was one of the dropped IDs, the earliest remaining
+		-- member takes over so the chat can still be managed.
+		UPDATE chat_members m SET role = 'owner'
+		FROM (
+			SELECT DISTINCT ON (c.chat_id) c.chat_id, c.user_id
+			FROM chat_members c
+			WHERE NOT EXISTS (SELECT 1 FROM chat_members o WHERE o.chat_id = c.chat_id AND o.role = 'owner')
+			ORDER BY c.chat_id, c.joined_at, c.user_id
+		) heir
+		WHERE m.chat_id = heir.chat_id AND m.user_id = heir.user_id;
+
+		DO $$
+		DECLARE
+			orphaned TEXT;
+		BEGIN
+			SELECT string_agg(chat_id, ', ') INTO orphaned
+			FROM chats c WHERE NOT EXISTS (SELECT 1 FROM chat_members m WHERE m.chat_id = c.chat_id);
+			IF orphaned IS NOT NULL THEN
+				RAISE WARNING 'chats % have no remaining members and can only be managed by site admins', orphaned;
+			END IF;
+		END $$;
+
+		ALTER TABLE chats DROP COLUMN IF EXISTS users;
+		ALTER TABLE chats DROP COLUMN IF EXISTS admins;
+		ALTER TABLE chats DROP COLUMN IF EXISTS owner;
+		ALTER TABLE chats DROP COLUMN IF EXISTS messages;
+
+		CREATE INDEX IF NOT EXISTS messages_chat_created_idx ON messages (chat_id, created_at);`,
+		Down: `
+		DROP INDEX IF EXISTS messages_chat_created_idx;
+
+		ALTER TABLE chats ADD COLUMN IF NOT EXISTS users TEXT[] NOT NULL DEFAULT '{}';
+		ALTER TABLE chats ADD COLUMN IF NOT EXISTS admins TEXT[] NOT NULL DEFAULT '{}';
+		ALTER TABLE chats ADD COLUMN IF NOT EXISTS owner TEXT;
+		ALTER TABLE chats ADD COLUMN IF NOT EXISTS messages TEXT[] DEFAULT '{}';
+
+		UPDATE chats c SET
+			users = ARRAY(SELECT user_id FROM chat_members m WHERE m.chat_id = c.chat_id ORDER BY m.joined_at, m.user_id),
+			admins = ARRAY(SELECT user_id FROM chat_members m WHERE m.chat_id = c.chat_id AND m.role = 'admin'),
+			owner = (SELECT user_id FROM chat_members m WHERE m.chat_id = c.chat_id AND m.role = 'owner'),
+			messages = ARRAY(SELECT message_id FROM messages WHERE messages.chat_id = c.chat_id ORDER BY created_at);
+
+		DROP TABLE IF EXISTS chat_members;`,
+	},
+	{
+		Version: 13,
+		Name:    "read_receipts",
+		Up: `
+		-- last_read_at matches messages.created_at so positions compare as
+		-- (created_at, message_id).
+		ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS last_read_message_id TEXT;
+		ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS last_read_at TIMESTAMP;`,
+		Down: `
+		ALTER TABLE chat_members DROP COLUMN IF EXISTS last_read_at;
+		ALTER TABLE chat_members DROP COLUMN IF EXISTS last_read_message_id;`,
+	},
+	{
+		Version: 14,
+		Name:    "message_edits_reactions",
+		Up: `
+		ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+		ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+		CREATE TABLE IF NOT EXISTS message_edits (
+			id BIGSERIAL PRIMARY KEY,
+			message_id TEXT NOT NULL REFERENCES messages(message_id) ON DELETE CASCADE,
+			text TEXT NOT NULL DEFAULT '',
+			media TEXT[] NOT NULL DEFAULT '{}',
+			edited_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS message_edits_message_idx ON message_edits (message_id, edited_at);
+
+		CREATE TABLE IF NOT EXISTS message_reactions (
+			message_id TEXT NOT NULL REFERENCES messages(message_id) ON DELETE CASCADE,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			emoji TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (message_id, user_id, emoji)
+		);`,
+		Down: `
+		DROP TABLE IF EXISTS message_reactions;
+		DROP TABLE IF EXISTS message_edits;
+
+		-- Tombstones have no content left to restore.
+		DELETE FROM messages WHERE deleted_at IS NOT NULL;
+		ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
+		ALTER TABLE messages DROP COLUMN IF EXISTS edited_at;`,
+	},
+	{
+		Version: 15,
+		Name:    "messages_position_index",
+		Up: `
+		-- Message history pages by (created_at, message_id).
+		CREATE INDEX IF NOT EXISTS messages_chat_position_idx ON messages (chat_id, created_at, message_id);
+		DROP INDEX IF EXISTS messages_chat_created_idx;`,
+		Down: `
+		CREATE INDEX IF NOT EXISTS messages_chat_created_idx ON messages (chat_id, created_at);
+		DROP INDEX IF EXISTS messages_chat_position_idx;`,
+	},
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const DefaultCurrency = "USD"
+
+// currencyExponents lists ISO 4217 currencies whose minor unit is not
+// hundredths. Every other currency is assumed to have two decimal places.
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+func currencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+func validCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Money is an exact amount in a currency's minor unit (cents for USD). It is
+// encoded in JSON as {"amount": "12.34", "currency": "USD"} so clients never
+// round-trip the value through a float.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+var errMoneyOverflow = invalid("Amount is too large")
+
+// Times returns m multiplied by quantity, or an ErrInvalid if the result does
+// not fit in an int64.
+func (m Money) Times(quantity int) (Money, error) {
+	a, q := m.Amount, int64(quantity)
+	if a == 0 || q == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	product := a * q
+	if product/q != a || (a == -1 && q == math.MinInt64) || (q == -1 && a == math.MinInt64) {
+		return m, errMoneyOverflow
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Add returns m + other; both must share a currency. It returns an
+// ErrInvalid if the sum does not fit in an int64.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return m, invalid("Cannot add %s to %s", other.Currency, m.Currency)
+	}
+	sum := m.Amount + other.Amount
+	if (sum > m.Amount) != (other.Amount > 0) {
+		return m, errMoneyOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// String formats the amount with the currency's decimal places, e.g. "12.34".
+func (m Money) String() string {
+	exp := currencyExponent(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if exp == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// ParseMoney parses a decimal string such as "12.34" into minor units of
+// currency, rejecting more decimal places than the currency has.
+func ParseMoney(amount string, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	if !validCurrency(currency) {
+		return Money{}, invalid("Invalid currency %q", currency)
+	}
+	exp := currencyExponent(currency)
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" || (hasPoint && frac == "") || len(frac) > exp {
+		return Money{}, invalid("Invalid amount %q for %s", amount, currency)
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || minor < 0 {
+		return Money{}, invalid("Invalid amount %q for %s", amount, currency)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.Currency})
+}
+
+// UnmarshalJSON accepts the amount as a string or a bare JSON number; either
+// way the decimal text is parsed directly, never via float64. Like a missing
+// field, null leaves m unchanged.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var raw moneyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	text := string(bytes.TrimSpace(raw.Amount))
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(raw.Amount, &text); err != nil {
+			return err
+		}
+	} else if strings.ContainsAny(text, "eE") {
+		return fmt.Errorf("amount must be a plain decimal, got %s", text)
+	}
+
+	parsed, err := ParseMoney(text, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
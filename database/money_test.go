@@ -0,0 +1,150 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     Money
+		wantErr  bool
+	}{
+		{amount: "12.34", currency: "USD", want: Money{Amount: 1234, Currency: "USD"}},
+		{amount: "12.3", currency: "usd", want: Money{Amount: 1230, Currency: "USD"}},
+		{amount: "12", currency: "", want: Money{Amount: 1200, Currency: DefaultCurrency}},
+		{amount: " -0.05 ", currency: "EUR", want: Money{Amount: -5, Currency: "EUR"}},
+		{amount: "500", currency: "JPY", want: Money{Amount: 500, Currency: "JPY"}},
+		{amount: "1.234", currency: "KWD", want: Money{Amount: 1234, Currency: "KWD"}},
+		{amount: "92233720368547758.07", currency: "USD", want: Money{Amount: math.MaxInt64, Currency: "USD"}},
+		{amount: "92233720368547758.08", currency: "USD", wantErr: true},
+		{amount: "12.345", currency: "USD", wantErr: true},
+		{amount: "1.5", currency: "JPY", wantErr: true},
+		{amount: "12.", currency: "USD", wantErr: true},
+		{amount: ".5", currency: "USD", wantErr: true},
+		{amount: "--1", currency: "USD", wantErr: true},
+		{amount: "1e3", currency: "USD", wantErr: true},
+		{amount: "", currency: "USD", wantErr: true},
+		{amount: "1", currency: "US", wantErr: true},
+		{amount: "1", currency: "U$D", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.amount, tt.currency)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("ParseMoney(%q, %q) error = %v, want ErrInvalid", tt.amount, tt.currency, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q, %q) = %v, %v, want %v", tt.amount, tt.currency, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{Amount: 1234, Currency: "USD"}, "12.34"},
+		{Money{Amount: 5, Currency: "USD"}, "0.05"},
+		{Money{Amount: 0, Currency: "USD"}, "0.00"},
+		{Money{Amount: -150, Currency: "EUR"}, "-1.50"},
+		{Money{Amount: 500, Currency: "JPY"}, "500"},
+		{Money{Amount: 7, Currency: "BHD"}, "0.007"},
+		{Money{Amount: math.MaxInt64, Currency: "USD"}, "92233720368547758.07"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyTimes(t *testing.T) {
+	tests := []struct {
+		amount   int64
+		quantity int
+		want     int64
+		wantErr  bool
+	}{
+		{amount: 250, quantity: 3, want: 750},
+		{amount: 250, quantity: 0, want: 0},
+		{amount: 0, quantity: math.MaxInt, want: 0},
+		{amount: -250, quantity: 2, want: -500},
+		{amount: math.MaxInt64, quantity: 1, want: math.MaxInt64},
+		{amount: math.MaxInt64 / 2, quantity: 2, want: math.MaxInt64 - 1},
+		{amount: math.MaxInt64/2 + 1, quantity: 2, wantErr: true},
+		{amount: math.MaxInt64, quantity: MaxItemQuantity, wantErr: true},
+		{amount: math.MinInt64, quantity: -1, wantErr: true},
+		{amount: -1, quantity: math.MinInt, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NewMoney(tt.amount, "USD").Times(tt.quantity)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("%d.Times(%d) error = %v, want ErrInvalid", tt.amount, tt.quantity, err)
+			}
+			continue
+		}
+		if err != nil || got != NewMoney(tt.want, "USD") {
+			t.Errorf("%d.Times(%d) = %v, %v, want %d", tt.amount, tt.quantity, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	tests := []struct {
+		a, b    Money
+		want    Money
+		wantErr bool
+	}{
+		{a: NewMoney(150, "USD"), b: NewMoney(250, "USD"), want: NewMoney(400, "USD")},
+		{a: NewMoney(150, "USD"), b: NewMoney(-250, "USD"), want: NewMoney(-100, "USD")},
+		{a: NewMoney(150, "USD"), b: NewMoney(0, "USD"), want: NewMoney(150, "USD")},
+		{a: NewMoney(math.MaxInt64-1, "USD"), b: NewMoney(1, "USD"), want: NewMoney(math.MaxInt64, "USD")},
+		{a: NewMoney(math.MaxInt64, "USD"), b: NewMoney(1, "USD"), wantErr: true},
+		{a: NewMoney(math.MinInt64, "USD"), b: NewMoney(-1, "USD"), wantErr: true},
+		{a: NewMoney(150, "USD"), b: NewMoney(150, "EUR"), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("%v.Add(%v) error = %v, want ErrInvalid", tt.a, tt.b, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%v.Add(%v) = %v, %v, want %v", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Money
+		wantErr bool
+	}{
+		{data: `{"amount": "12.34", "currency": "EUR"}`, want: NewMoney(1234, "EUR")},
+		{data: `{"amount": 12.34}`, want: NewMoney(1234, DefaultCurrency)},
+		{data: `{"amount": 0.1}`, want: NewMoney(10, DefaultCurrency)},
+		{data: `null`, want: Money{}},
+		{data: `{"amount": 1e3}`, wantErr: true},
+		{data: `{"amount": "1.001"}`, wantErr: true},
+		{data: `{}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := json.Unmarshal([]byte(tt.data), &got)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v (error %t)", tt.data, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
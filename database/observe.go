@@ -0,0 +1,17 @@
+package database
+
+import "time"
+
+// QueryObserver, if set, is told how long each Postgres store method took.
+// It lets the server export timings without this package depending on a
+// metrics library.
+var QueryObserver func(store string, method string, elapsed time.Duration)
+
+// observe is deferred at the top of store methods:
+//
+//	defer observe("users", "GetByID", time.Now())
+func observe(store string, method string, start time.Time) {
+	if QueryObserver != nil {
+		QueryObserver(store, method, time.Since(start))
+	}
+}
@@ -1,160 +1,370 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
-	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
-func CreateOrdersTable(db *sql.DB) error {
-	query := `
-    CREATE TABLE IF NOT EXISTS orders (
-        order_number SERIAL PRIMARY KEY,
-        status TEXT NOT NULL,
-        user_id TEXT NOT NULL,
-        products JSONB NOT NULL,
-        total FLOAT8 NOT NULL,
-        created_at TIMESTAMP DEFAULT NOW(),
-        updated_at TIMESTAMP DEFAULT NOW()
-    );`
-
-	_, err := db.Exec(query)
-	return err
+// MaxItemQuantity bounds the quantity of one order line, keeping line totals
+// well clear of int64 overflow and within the INT column.
+const MaxItemQuantity = 1_000_000
+
+// normalizeItems validates requested line items and merges repeated UPCs,
+// preserving first-seen order.
+func normalizeItems(items []OrderItem) ([]OrderItem, error) {
+	if len(items) == 0 {
+		return nil, invalid("Order must contain at least one item")
+	}
+
+	var merged []OrderItem
+	index := make(map[string]int)
+	for _, item := range items {
+		if item.UPC == "" {
+			return nil, invalid("Order item is missing a upc")
+		}
+		if item.Quantity <= 0 {
+			return nil, invalid("Quantity for product %s must be positive", item.UPC)
+		}
+		if item.Quantity > MaxItemQuantity {
+			return nil, invalid("Quantity for product %s must be at most %d", item.UPC, MaxItemQuantity)
+		}
+		if i, ok := index[item.UPC]; ok {
+			merged[i].Quantity += item.Quantity
+			if merged[i].Quantity > MaxItemQuantity {
+				return nil, invalid("Quantity for product %s must be at most %d", item.UPC, MaxItemQuantity)
+			}
+			continue
+		}
+		index[item.UPC] = len(merged)
+		merged = append(merged, OrderItem{UPC: item.UPC, Quantity: item.Quantity})
+	}
+	return merged, nil
 }
 
-func CreateOrder(db *sql.DB, c *gin.Context) {
+// priceItems fills in unit and line prices from the catalogue and returns the
+// order total. lookup reports false for an unknown UPC. All items must share
+// one currency.
+func priceItems(items []OrderItem, lookup func(upc string) (Product, bool)) (Money, error) {
+	var total Money
+	for i := range items {
+		product, ok := lookup(items[i].UPC)
+		if !ok {
+			return total, invalid("Unknown product %s", items[i].UPC)
+		}
+		items[i].Name = product.Name
+		items[i].UnitPrice = product.Price
+		var err error
+		if items[i].LineTotal, err = product.Price.Times(items[i].Quantity); err != nil {
+			return total, err
+		}
+
+		if i == 0 {
+			total = Money{Currency: product.Price.Currency}
+		}
+		if total, err = total.Add(items[i].LineTotal); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+const orderColumns = `order_number, status, user_id, total_minor, currency, created_at, updated_at`
+
+func scanOrder(row scanner) (Order, error) {
 	var order Order
-	if err := c.ShouldBindJSON(&order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	err := row.Scan(&order.OrderNumber, &order.Status, &order.User, &order.Total.Amount, &order.Total.Currency, &order.CreatedAt, &order.UpdatedAt)
+	return order, err
+}
+
+type pgOrderStore struct {
+	db *sql.DB
+}
+
+func NewPostgresOrderStore(db *sql.DB) OrderStore {
+	return &pgOrderStore{db: db}
+}
+
+func (s *pgOrderStore) Create(ctx context.Context, order Order) (Order, error) {
+	defer observe("orders", "Create", time.Now())
+	items, err := normalizeItems(order.Items)
+	if err != nil {
+		return order, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return order, err
+	}
+	defer tx.Rollback()
+
+	upcs := make([]string, len(items))
+	for i, item := range items {
+		upcs[i] = item.UPC
 	}
 
-	productsJSON, err := json.Marshal(order.Products)
+	rows, err := tx.QueryContext(ctx, `SELECT upc, name, price_minor, currency FROM products WHERE upc = ANY($1) FOR SHARE`, pq.Array(upcs))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return order, err
+	}
+	catalogue := make(map[string]Product)
+	for rows.Next() {
+		var product Product
+		if err := rows.Scan(&product.UPC, &product.Name, &product.Price.Amount, &product.Price.Currency); err != nil {
+			rows.Close()
+			return order, err
+		}
+		catalogue[product.UPC] = product
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return order, err
 	}
 
-	query := `INSERT INTO orders (status, user_id, products, total, created_at, updated_at)
-              VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING order_number`
+	order.Total, err = priceItems(items, func(upc string) (Product, bool) {
+		product, ok := catalogue[upc]
+		return product, ok
+	})
+	if err != nil {
+		return order, err
+	}
+	order.Items = items
 
-	err = db.QueryRowContext(c, query, order.Status, order.User, productsJSON, order.Total).Scan(&order.OrderNumber)
+	query := `INSERT INTO orders (status, user_id, total_minor, currency, created_at, updated_at)
+              VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING order_number, created_at, updated_at`
+	err = tx.QueryRowContext(ctx, query, order.Status, order.User, order.Total.Amount, order.Total.Currency).Scan(&order.OrderNumber, &order.CreatedAt, &order.UpdatedAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return order, mapError(err, "Order")
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Order created!", "orderNumber": order.OrderNumber})
+	itemQuery := `INSERT INTO order_items (order_number, upc, quantity, unit_price_minor) VALUES ($1, $2, $3, $4)`
+	for _, item := range items {
+		if _, err := tx.ExecContext(ctx, itemQuery, order.OrderNumber, item.UPC, item.Quantity, item.UnitPrice.Amount); err != nil {
+			return order, mapError(err, "Order item")
+		}
+	}
+
+	return order, tx.Commit()
 }
 
-func GetOrders(db *sql.DB, c *gin.Context) {
-	query := `SELECT order_number, status, user_id, products, total, created_at, updated_at FROM orders`
-	rows, err := db.QueryContext(c, query)
+// loadItems attaches line items, expanded with product names, to orders.
+func (s *pgOrderStore) loadItems(ctx context.Context, orders []Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	numbers := make([]int64, len(orders))
+	index := make(map[int]int, len(orders))
+	for i, order := range orders {
+		numbers[i] = int64(order.OrderNumber)
+		index[order.OrderNumber] = i
+	}
+
+	query := `SELECT i.order_number, i.upc, p.name, i.quantity, i.unit_price_minor, o.currency
+		FROM order_items i
+		JOIN products p ON p.upc = i.upc
+		JOIN orders o ON o.order_number = i.order_number
+		WHERE i.order_number = ANY($1)
+		ORDER BY i.order_number, i.upc`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(numbers))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
 	defer rows.Close()
 
-	var orders []Order
 	for rows.Next() {
-		var order Order
-		var productsData []byte
-		if err := rows.Scan(&order.OrderNumber, &order.Status, &order.User, &productsData, &order.Total, &order.CreatedAt, &order.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+		var orderNumber int
+		var item OrderItem
+		if err := rows.Scan(&orderNumber, &item.UPC, &item.Name, &item.Quantity, &item.UnitPrice.Amount, &item.UnitPrice.Currency); err != nil {
+			return err
+		}
+		if item.LineTotal, err = item.UnitPrice.Times(item.Quantity); err != nil {
+			return err
 		}
-		json.Unmarshal(productsData, &order.Products)
-		orders = append(orders, order)
+		i := index[orderNumber]
+		orders[i].Items = append(orders[i].Items, item)
 	}
+	return rows.Err()
+}
 
-	if err := rows.Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+func (s *pgOrderStore) Get(ctx context.Context, orderNumber int) (Order, error) {
+	defer observe("orders", "Get", time.Now())
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE order_number = $1`
+	order, err := scanOrder(s.db.QueryRowContext(ctx, query, orderNumber))
+	if err == sql.ErrNoRows {
+		return order, notFound("Order")
+	} else if err != nil {
+		return order, err
 	}
 
-	c.JSON(http.StatusOK, orders)
+	orders := []Order{order}
+	err = s.loadItems(ctx, orders)
+	return orders[0], err
 }
 
-func GetOrderByNumber(db *sql.DB, c *gin.Context) {
-	orderNumber := c.Param("orderNumber")
-	query := `SELECT order_number, status, user_id, products, total, created_at, updated_at FROM orders WHERE order_number = $1`
+var orderList = listSpec[Order]{
+	selectSQL: `SELECT ` + orderColumns + ` FROM orders`,
+	idColumn:  "order_number",
+	idCast:    "int",
+	id:        func(o Order) string { return strconv.Itoa(o.OrderNumber) },
+	sorts: map[string]sortField[Order]{
+		"order_number": {
+			column: "order_number", cast: "int",
+			value: func(o Order) string { return strconv.Itoa(o.OrderNumber) },
+			less:  func(a, b Order) bool { return a.OrderNumber < b.OrderNumber },
+		},
+		"created_at": {
+			column: "created_at", cast: "timestamp",
+			value: func(o Order) string { return o.CreatedAt },
+			less:  func(a, b Order) bool { return a.CreatedAt < b.CreatedAt },
+		},
+		"total": {
+			column: "total_minor", cast: "bigint",
+			value: func(o Order) string { return strconv.FormatInt(o.Total.Amount, 10) },
+			less:  func(a, b Order) bool { return a.Total.Amount < b.Total.Amount },
+		},
+	},
+	defaultSort: "order_number",
+}
 
-	var order Order
-	var productsData []byte
-	err := db.QueryRowContext(c, query, orderNumber).Scan(&order.OrderNumber, &order.Status, &order.User, &productsData, &order.Total, &order.CreatedAt, &order.UpdatedAt)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+func (filter OrderFilter) validate() error {
+	if filter.Status != "" && !filter.Status.Valid() {
+		return invalid("Unknown order status %q", filter.Status)
 	}
-
-	json.Unmarshal(productsData, &order.Products)
-	c.JSON(http.StatusOK, order)
+	return nil
 }
 
-func UpdateOrderByNumber(db *sql.DB, c *gin.Context) {
-	orderNumber := c.Param("orderNumber")
-	var order Order
-	if err := c.ShouldBindJSON(&order); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+func (s *pgOrderStore) List(ctx context.Context, filter OrderFilter, page PageRequest) (Page[Order], error) {
+	defer observe("orders", "List", time.Now())
+	if err := filter.validate(); err != nil {
+		return Page[Order]{}, err
+	}
+
+	var b queryBuilder
+	if filter.Status != "" {
+		b.where("status = ?", filter.Status)
+	}
+	if filter.User != "" {
+		b.where("user_id = ?", filter.User)
+	}
+	if filter.CreatedAfter != nil {
+		b.where("created_at >= ?", filter.CreatedAfter.UTC())
+	}
+	if filter.CreatedBefore != nil {
+		b.where("created_at < ?", filter.CreatedBefore.UTC())
 	}
 
-	productsJSON, err := json.Marshal(order.Products)
+	result, err := queryPage(ctx, s.db, orderList, b, page, scanOrder)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return result, err
 	}
+	return result, s.loadItems(ctx, result.Items)
+}
 
-	query := `UPDATE orders 
-              SET status=$1, user_id=$2, products=$3, total=$4, updated_at=NOW()
-              WHERE order_number=$5`
+// Update changes an order's owner. Line items and the total are fixed when
+// the order is created.
+func (s *pgOrderStore) Update(ctx context.Context, order Order) error {
+	defer observe("orders", "Update", time.Now())
+	query := `UPDATE orders
+              SET user_id=$1, updated_at=NOW()
+              WHERE order_number=$2`
 
-	result, err := db.ExecContext(c, query, order.Status, order.User, productsJSON, order.Total, orderNumber)
+	result, err := s.db.ExecContext(ctx, query, order.User, order.OrderNumber)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return mapError(err, "Order")
 	}
+	return rowsAffectedOrNotFound(result, "Order")
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *pgOrderStore) UpdateStatus(ctx context.Context, orderNumber int, status OrderStatus, changedBy string) (Order, error) {
+	defer observe("orders", "UpdateStatus", time.Now())
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return Order{}, err
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		return
+	var current OrderStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE order_number = $1 FOR UPDATE`, orderNumber).Scan(&current)
+	if err == sql.ErrNoRows {
+		return Order{}, notFound("Order")
+	} else if err != nil {
+		return Order{}, err
+	}
+
+	if !current.CanTransitionTo(status) {
+		return Order{}, &transitionError{from: current, to: status}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE orders SET status=$1, updated_at=NOW() WHERE order_number=$2`, status, orderNumber); err != nil {
+		return Order{}, err
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order updated!"})
+	historyQuery := `INSERT INTO order_status_history (order_number, from_status, to_status, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, NOW())`
+	if _, err := tx.ExecContext(ctx, historyQuery, orderNumber, current, status, changedBy); err != nil {
+		return Order{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Order{}, err
+	}
+	return s.Get(ctx, orderNumber)
 }
 
-func DeleteOrderByNumber(db *sql.DB, c *gin.Context) {
-	orderNumber := c.Param("orderNumber")
-	query := `DELETE FROM orders WHERE order_number = $1`
+func (s *pgOrderStore) History(ctx context.Context, orderNumber int) ([]OrderStatusChange, error) {
+	defer observe("orders", "History", time.Now())
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_number = $1)`, orderNumber).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, notFound("Order")
+	}
 
-	result, err := db.ExecContext(c, query, orderNumber)
+	query := `SELECT order_number, from_status, to_status, changed_by, changed_at
+		FROM order_status_history WHERE order_number = $1 ORDER BY changed_at ASC, id ASC`
+	rows, err := s.db.QueryContext(ctx, query, orderNumber)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
+	defer rows.Close()
+
+	var history []OrderStatusChange
+	for rows.Next() {
+		var change OrderStatusChange
+		if err := rows.Scan(&change.OrderNumber, &change.FromStatus, &change.ToStatus, &change.ChangedBy, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *pgOrderStore) Delete(ctx context.Context, orderNumber int) error {
+	defer observe("orders", "Delete", time.Now())
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
+	defer tx.Rollback()
 
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		return
+	var current OrderStatus
+	err = tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE order_number = $1 FOR UPDATE`, orderNumber).Scan(&current)
+	if err == sql.ErrNoRows {
+		return notFound("Order")
+	} else if err != nil {
+		return err
+	}
+	if current != NotSent {
+		return &deleteSentError{status: current}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order deleted!"})
+	if _, err := tx.ExecContext(ctx, `DELETE FROM orders WHERE order_number = $1`, orderNumber); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
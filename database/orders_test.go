@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func newTestOrder(t *testing.T, orders OrderStore) Order {
+	t.Helper()
+	order, err := orders.Create(context.Background(), Order{Status: NotSent, User: "user-1", Items: []OrderItem{{UPC: "upc-1", Quantity: 1}}})
+	if err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+	return order
+}
+
+func TestOrderStatusTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []OrderStatus
+		next    OrderStatus
+		wantErr bool
+	}{
+		{name: "send", next: Sent},
+		{name: "cancel before sending", next: Cancelled},
+		{name: "full lifecycle", path: []OrderStatus{Sent, Received, InProgress, InTransit}, next: Delivered},
+		{name: "refund delivered", path: []OrderStatus{Sent, Received, InProgress, InTransit, Delivered}, next: Refunded},
+		{name: "refund cancelled", path: []OrderStatus{Cancelled}, next: Refunded},
+		{name: "skip a step", next: Received, wantErr: true},
+		{name: "go back", path: []OrderStatus{Sent}, next: NotSent, wantErr: true},
+		{name: "same status", path: []OrderStatus{Sent}, next: Sent, wantErr: true},
+		{name: "cancel in transit", path: []OrderStatus{Sent, Received, InProgress, InTransit}, next: Cancelled, wantErr: true},
+		{name: "refund not delivered", path: []OrderStatus{Sent}, next: Refunded, wantErr: true},
+		{name: "leave refunded", path: []OrderStatus{Cancelled, Refunded}, next: NotSent, wantErr: true},
+		{name: "unknown status", next: OrderStatus("Lost"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores := NewMemoryStores()
+			if err := stores.Products.Create(ctx, Product{UPC: "upc-1", Name: "Widget", Price: NewMoney(999, "USD")}); err != nil {
+				t.Fatal(err)
+			}
+			order := newTestOrder(t, stores.Orders)
+			for _, status := range tt.path {
+				if _, err := stores.Orders.UpdateStatus(ctx, order.OrderNumber, status, "staff-1"); err != nil {
+					t.Fatalf("moving to %s: %v", status, err)
+				}
+			}
+
+			got, err := stores.Orders.UpdateStatus(ctx, order.OrderNumber, tt.next, "staff-1")
+			history, _ := stores.Orders.History(ctx, order.OrderNumber)
+			if tt.wantErr {
+				if !errors.Is(err, ErrConflict) {
+					t.Fatalf("UpdateStatus(%s) error = %v, want ErrConflict", tt.next, err)
+				}
+				if len(history) != len(tt.path) {
+					t.Errorf("history has %d changes after a rejected transition, want %d", len(history), len(tt.path))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateStatus(%s): %v", tt.next, err)
+			}
+			if got.Status != tt.next {
+				t.Errorf("status = %s, want %s", got.Status, tt.next)
+			}
+			if len(history) != len(tt.path)+1 {
+				t.Fatalf("history has %d changes, want %d", len(history), len(tt.path)+1)
+			}
+			last := history[len(history)-1]
+			if last.ToStatus != tt.next || last.ChangedBy != "staff-1" {
+				t.Errorf("last change = %+v, want to %s by staff-1", last, tt.next)
+			}
+		})
+	}
+}
+
+func TestOrderStatusUnknownOrder(t *testing.T) {
+	stores := NewMemoryStores()
+	if _, err := stores.Orders.UpdateStatus(context.Background(), 42, Sent, "staff-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateStatus on a missing order error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []OrderStatus
+		wantErr error
+	}{
+		{name: "not sent"},
+		{name: "sent", path: []OrderStatus{Sent}, wantErr: ErrConflict},
+		{name: "cancelled", path: []OrderStatus{Cancelled}, wantErr: ErrConflict},
+		{name: "refunded", path: []OrderStatus{Sent, Received, InProgress, InTransit, Delivered, Refunded}, wantErr: ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores := NewMemoryStores()
+			if err := stores.Products.Create(ctx, Product{UPC: "upc-1", Name: "Widget", Price: NewMoney(999, "USD")}); err != nil {
+				t.Fatal(err)
+			}
+			order := newTestOrder(t, stores.Orders)
+			for _, status := range tt.path {
+				if _, err := stores.Orders.UpdateStatus(ctx, order.OrderNumber, status, "staff-1"); err != nil {
+					t.Fatalf("moving to %s: %v", status, err)
+				}
+			}
+
+			err := stores.Orders.Delete(ctx, order.OrderNumber)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Delete error = %v, want %v", err, tt.wantErr)
+			}
+			history, _ := stores.Orders.History(ctx, order.OrderNumber)
+			if len(history) != len(tt.path) {
+				t.Errorf("history has %d changes after Delete, want %d", len(history), len(tt.path))
+			}
+		})
+	}
+
+	stores := NewMemoryStores()
+	if err := stores.Orders.Delete(context.Background(), 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting a missing order error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOrderTotals(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []OrderItem
+		want    Money
+		wantErr bool
+	}{
+		{name: "one line", items: []OrderItem{{UPC: "cheap", Quantity: 3}}, want: NewMoney(750, "USD")},
+		{name: "merges repeated upcs", items: []OrderItem{{UPC: "cheap", Quantity: 1}, {UPC: "cheap", Quantity: 2}}, want: NewMoney(750, "USD")},
+		{name: "max quantity", items: []OrderItem{{UPC: "cheap", Quantity: MaxItemQuantity}}, want: NewMoney(250*MaxItemQuantity, "USD")},
+		{name: "quantity too large", items: []OrderItem{{UPC: "cheap", Quantity: MaxItemQuantity + 1}}, wantErr: true},
+		{name: "merged quantity too large", items: []OrderItem{{UPC: "cheap", Quantity: MaxItemQuantity}, {UPC: "cheap", Quantity: 1}}, wantErr: true},
+		{name: "line total overflows", items: []OrderItem{{UPC: "huge", Quantity: 2}}, wantErr: true},
+		{name: "total overflows", items: []OrderItem{{UPC: "huge", Quantity: 1}, {UPC: "cheap", Quantity: 1}}, wantErr: true},
+		{name: "zero quantity", items: []OrderItem{{UPC: "cheap", Quantity: 0}}, wantErr: true},
+		{name: "unknown product", items: []OrderItem{{UPC: "missing", Quantity: 1}}, wantErr: true},
+		{name: "no items", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores := NewMemoryStores()
+			for _, product := range []Product{
+				{UPC: "cheap", Name: "Cheap", Price: NewMoney(250, "USD")},
+				{UPC: "huge", Name: "Huge", Price: NewMoney(math.MaxInt64, "USD")},
+			} {
+				if err := stores.Products.Create(ctx, product); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			order, err := stores.Orders.Create(ctx, Order{Status: NotSent, User: "user-1", Items: tt.items})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalid) {
+					t.Errorf("Create error = %v, want ErrInvalid", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if order.Total != tt.want {
+				t.Errorf("total = %v, want %v", order.Total, tt.want)
+			}
+		})
+	}
+}
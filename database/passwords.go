@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const MinPasswordLength = 8
+
+var PasswordResetTTL = time.Hour
+
+var (
+	ErrPasswordMismatch  = errors.New("current password is incorrect")
+	ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+)
+
+// PasswordReset is a pending reset. Only the SHA-256 of the token is stored;
+// the token itself is only ever emailed to the user.
+type PasswordReset struct {
+	TokenHash string
+	UserID    string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+func validatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return invalid("Password must be at least %d characters", MinPasswordLength)
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// setPassword hashes password, stores it for userID and signs the user out
+// of every existing session.
+func setPassword(ctx context.Context, users UserStore, tokens TokenStore, userID string, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	hash, err := HashedPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := users.UpdatePassword(ctx, userID, hash); err != nil {
+		return err
+	}
+	return tokens.RevokeUserTokens(ctx, userID)
+}
+
+// ChangePassword replaces a user's password after checking the current one.
+func ChangePassword(ctx context.Context, users UserStore, tokens TokenStore, userID string, current string, password string) error {
+	user, err := users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !CheckPasswordHash(current, user.Password) {
+		return ErrPasswordMismatch
+	}
+	return setPassword(ctx, users, tokens, userID, password)
+}
+
+// RequestPasswordReset records a new reset for user and returns the token to
+// send them.
+func RequestPasswordReset(ctx context.Context, tokens TokenStore, user User) (string, error) {
+	token, err := newRandomToken()
+	if err != nil {
+		return "", err
+	}
+	err = tokens.CreatePasswordReset(ctx, PasswordReset{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(PasswordResetTTL),
+	})
+	return token, err
+}
+
+// ResetPassword consumes a reset token and sets the new password.
+func ResetPassword(ctx context.Context, users UserStore, tokens TokenStore, token string, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	reset, err := tokens.ConsumePasswordReset(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	return setPassword(ctx, users, tokens, reset.UserID, password)
+}
+
+func (s *pgTokenStore) RevokeUserTokens(ctx context.Context, userID string) error {
+	defer observe("tokens", "RevokeUserTokens", time.Now())
+	_, err := s.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
+
+func (s *pgTokenStore) CreatePasswordReset(ctx context.Context, reset PasswordReset) error {
+	defer observe("tokens", "CreatePasswordReset", time.Now())
+	query := `INSERT INTO password_reset_tokens (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())`
+	_, err := s.db.ExecContext(ctx, query, reset.TokenHash, reset.UserID, reset.ExpiresAt)
+	return err
+}
+
+func (s *pgTokenStore) ConsumePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	defer observe("tokens", "ConsumePasswordReset", time.Now())
+	reset := PasswordReset{TokenHash: tokenHash}
+	query := `UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id, expires_at`
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&reset.UserID, &reset.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return reset, ErrResetTokenInvalid
+	}
+	return reset, err
+}
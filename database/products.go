@@ -1,140 +1,143 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/lib/pq"
 )
 
-func CreateProductsTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE products (
-		upc TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		description TEXT,
-		price FLOAT,
-		images TEXT[], 
-		created_at TIMESTAMP DEFAULT NOW(),
-		updated_at TIMESTAMP DEFAULT NOW()
-	);`
+const productColumns = `upc, name, description, price_minor, currency, images, created_at, updated_at`
 
-	_, err := db.Exec(query)
-	return err
+func scanProduct(row scanner) (Product, error) {
+	var product Product
+	var images pq.StringArray
+	err := row.Scan(&product.UPC, &product.Name, &product.Description, &product.Price.Amount, &product.Price.Currency, &images, &product.CreatedAt, &product.UpdatedAt)
+	product.Images = []string(images) // Convert pq.StringArray to []string
+	return product, err
 }
 
-func CreateProduct(db *sql.DB, c *gin.Context) {
-	var product Product
-	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+type pgProductStore struct {
+	db *sql.DB
+}
 
-	query := `INSERT INTO products (upc, name, description, price, images, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, NOW(), NOW())`
-	_, err := db.ExecContext(c, query, product.UPC, product.Name, product.Description, product.Price, pq.Array(product.Images))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+func NewPostgresProductStore(db *sql.DB) ProductStore {
+	return &pgProductStore{db: db}
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Product created!"})
+func (s *pgProductStore) Create(ctx context.Context, product Product) error {
+	defer observe("products", "Create", time.Now())
+	query := `INSERT INTO products (upc, name, description, price_minor, currency, images, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())`
+	_, err := s.db.ExecContext(ctx, query, product.UPC, product.Name, product.Description, product.Price.Amount, product.Price.Currency, pq.Array(product.Images))
+	return mapError(err, "Product")
 }
 
-func GetProducts(db *sql.DB, c *gin.Context) {
-	rows, err := db.QueryContext(c, "SELECT upc, name, description, price, images, created_at, updated_at FROM products;")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+func (s *pgProductStore) Get(ctx context.Context, upc string) (Product, error) {
+	defer observe("products", "Get", time.Now())
+	query := `SELECT ` + productColumns + ` FROM products WHERE upc = $1`
+	product, err := scanProduct(s.db.QueryRowContext(ctx, query, upc))
+	if err == sql.ErrNoRows {
+		return product, notFound("Product")
 	}
-	defer rows.Close()
+	return product, err
+}
 
-	var products []Product
-	for rows.Next() {
-		var product Product
-		var images pq.StringArray
+var productList = listSpec[Product]{
+	selectSQL: `SELECT ` + productColumns + ` FROM products`,
+	idColumn:  "upc",
+	idCast:    "text",
+	id:        func(p Product) string { return p.UPC },
+	sorts: map[string]sortField[Product]{
+		"name": {
+			column: "name", cast: "text",
+			value: func(p Product) string { return p.Name },
+			less:  func(a, b Product) bool { return a.Name < b.Name },
+		},
+		"price": {
+			column: "price_minor", cast: "bigint",
+			value: func(p Product) string { return strconv.FormatInt(p.Price.Amount, 10) },
+			less:  func(a, b Product) bool { return a.Price.Amount < b.Price.Amount },
+		},
+		"created_at": {
+			column: "created_at", cast: "timestamp",
+			value: func(p Product) string { return p.CreatedAt },
+			less:  func(a, b Product) bool { return a.CreatedAt < b.CreatedAt },
+		},
+	},
+	defaultSort: "name",
+}
 
-		if err := rows.Scan(&product.UPC, &product.Name, &product.Description, &product.Price, &images, &product.CreatedAt, &product.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+// priceRange checks that the bounds of filter share a currency and returns
+// it, or "" when neither bound is set.
+func (filter ProductFilter) priceRange() (string, error) {
+	switch {
+	case filter.MinPrice != nil && filter.MaxPrice != nil:
+		if filter.MinPrice.Currency != filter.MaxPrice.Currency {
+			return "", invalid("Price bounds must use the same currency")
 		}
-		product.Images = []string(images) // Convert pq.StringArray to []string
-		products = append(products, product)
-	}
-
-	if err := rows.Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return filter.MinPrice.Currency, nil
+	case filter.MinPrice != nil:
+		return filter.MinPrice.Currency, nil
+	case filter.MaxPrice != nil:
+		return filter.MaxPrice.Currency, nil
 	}
-
-	c.JSON(http.StatusOK, products)
+	return "", nil
 }
 
-func GetProductByUPC(db *sql.DB, c *gin.Context) {
-	upc := c.Param("upc")
-	var product Product
-	query := `SELECT upc, name, description, price, created_at, updated_at FROM products WHERE upc = $1`
-	err := db.QueryRowContext(c, query, upc).Scan(&product.UPC, &product.Name, &product.Description, &product.Price, &product.CreatedAt, &product.UpdatedAt)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+func (filter ProductFilter) matches(product Product) bool {
+	if !hasPrefixFold(product.Name, filter.NamePrefix) {
+		return false
 	}
-
-	c.JSON(http.StatusOK, product)
-}
-
-func UpdateProductByUPC(db *sql.DB, c *gin.Context) {
-	upc := c.Param("upc")
-	var product Product
-	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if filter.MinPrice != nil && (product.Price.Currency != filter.MinPrice.Currency || product.Price.Amount < filter.MinPrice.Amount) {
+		return false
 	}
-
-	query := `UPDATE products SET name=$1, description=$2, price=$3, updated_at=NOW() WHERE upc=$4`
-	result, err := db.ExecContext(c, query, product.Name, product.Description, product.Price, upc)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if filter.MaxPrice != nil && (product.Price.Currency != filter.MaxPrice.Currency || product.Price.Amount > filter.MaxPrice.Amount) {
+		return false
 	}
+	return true
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *pgProductStore) List(ctx context.Context, filter ProductFilter, page PageRequest) (Page[Product], error) {
+	defer observe("products", "List", time.Now())
+	currency, err := filter.priceRange()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return Page[Product]{}, err
 	}
 
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
+	var b queryBuilder
+	if filter.NamePrefix != "" {
+		b.where("name ILIKE ?", escapeLike(filter.NamePrefix)+"%")
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Product updated!"})
+	if currency != "" {
+		b.where("currency = ?", currency)
+	}
+	if filter.MinPrice != nil {
+		b.where("price_minor >= ?", filter.MinPrice.Amount)
+	}
+	if filter.MaxPrice != nil {
+		b.where("price_minor <= ?", filter.MaxPrice.Amount)
+	}
+	return queryPage(ctx, s.db, productList, b, page, scanProduct)
 }
 
-func DeleteProductByUPC(db *sql.DB, c *gin.Context) {
-	upc := c.Param("upc")
-	query := `DELETE FROM products WHERE upc = $1`
-	result, err := db.ExecContext(c, query, upc)
+func (s *pgProductStore) Update(ctx context.Context, product Product) error {
+	defer observe("products", "Update", time.Now())
+	query := `UPDATE products SET name=$1, description=$2, price_minor=$3, currency=$4, updated_at=NOW() WHERE upc=$5`
+	result, err := s.db.ExecContext(ctx, query, product.Name, product.Description, product.Price.Amount, product.Price.Currency, product.UPC)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return mapError(err, "Product")
 	}
+	return rowsAffectedOrNotFound(result, "Product")
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *pgProductStore) Delete(ctx context.Context, upc string) error {
+	defer observe("products", "Delete", time.Now())
+	result, err := s.db.ExecContext(ctx, `DELETE FROM products WHERE upc = $1`, upc)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return mapError(err, "Product")
 	}
-
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Product deleted!"})
+	return rowsAffectedOrNotFound(result, "Product")
 }
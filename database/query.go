@@ -0,0 +1,284 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// PageRequest asks for one page of a list. Cursor is the NextCursor of the
+// previous page and is only valid with the same Sort and Desc.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	Desc   bool
+}
+
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// MessagePageRequest asks for one page of a chat's history: the Limit
+// messages just before Before, just after After, or the latest ones if
+// neither is set. Before and After are message IDs; at most one may be set.
+type MessagePageRequest struct {
+	Before string
+	After  string
+	Limit  int
+}
+
+// MessagePage is a page of a chat's history, newest first. HasMoreBefore
+// means older messages exist; HasMoreAfter means newer ones do.
+type MessagePage struct {
+	Messages      []Message `json:"messages"`
+	HasMoreBefore bool      `json:"has_more_before"`
+	HasMoreAfter  bool      `json:"has_more_after"`
+}
+
+// newMessagePage builds a MessagePage from up to limit+1 messages in the
+// order they were fetched, nearest to the cursor first.
+func newMessagePage(messages []Message, req MessagePageRequest, limit int) MessagePage {
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	if messages == nil {
+		messages = []Message{}
+	}
+
+	if req.After != "" {
+		slices.Reverse(messages)
+		return MessagePage{Messages: messages, HasMoreBefore: true, HasMoreAfter: hasMore}
+	}
+	return MessagePage{Messages: messages, HasMoreBefore: hasMore, HasMoreAfter: req.Before != ""}
+}
+
+// pageCursor is the decoded form of an opaque cursor: the sort key and ID
+// of the last row returned.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    string `json:"i"`
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var cursor pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, invalid("Invalid cursor")
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, invalid("Invalid cursor")
+	}
+	return cursor, nil
+}
+
+// sortField describes one way a list can be ordered.
+type sortField[T any] struct {
+	column string // SQL expression to order by
+	cast   string // Postgres type the cursor value is cast back to
+	value  func(T) string
+	less   func(a, b T) bool
+}
+
+// listSpec describes a listable table so every list endpoint pages, sorts
+// and filters the same way.
+type listSpec[T any] struct {
+	selectSQL   string // SELECT ... FROM ..., without WHERE
+	idColumn    string
+	idCast      string
+	id          func(T) string
+	sorts       map[string]sortField[T]
+	defaultSort string
+}
+
+type resolvedPage[T any] struct {
+	field  sortField[T]
+	sort   string
+	limit  int
+	cursor *pageCursor
+}
+
+func (spec listSpec[T]) resolve(req PageRequest) (resolvedPage[T], error) {
+	var page resolvedPage[T]
+
+	page.sort = req.Sort
+	if page.sort == "" {
+		page.sort = spec.defaultSort
+	}
+	field, ok := spec.sorts[page.sort]
+	if !ok {
+		return page, invalid("Cannot sort by %q", req.Sort)
+	}
+	page.field = field
+
+	page.limit = pageLimit(req.Limit)
+
+	if req.Cursor != "" {
+		cursor, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return page, err
+		}
+		if cursor.Sort != page.sort || cursor.Desc != req.Desc {
+			return page, invalid("Cursor does not match the requested sort")
+		}
+		page.cursor = &cursor
+	}
+	return page, nil
+}
+
+func (page resolvedPage[T]) next(spec listSpec[T], items []T, desc bool) Page[T] {
+	result := Page[T]{Items: items}
+	if len(items) > page.limit {
+		result.Items = items[:page.limit]
+		last := result.Items[page.limit-1]
+		result.NextCursor = encodeCursor(pageCursor{
+			Sort:  page.sort,
+			Desc:  desc,
+			Value: page.field.value(last),
+			ID:    spec.id(last),
+		})
+	}
+	return result
+}
+
+// pageLimit applies the default and maximum page size to a requested limit.
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+	return min(limit, MaxPageLimit)
+}
+
+// queryBuilder accumulates WHERE conditions written with ? placeholders and
+// numbers them as $1, $2, ... in the order they are added.
+type queryBuilder struct {
+	conds []string
+	args  []any
+}
+
+func (b *queryBuilder) where(cond string, args ...any) {
+	for _, arg := range args {
+		b.args = append(b.args, arg)
+		cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(b.args)), 1)
+	}
+	b.conds = append(b.conds, cond)
+}
+
+func (b *queryBuilder) whereSQL() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// hasPrefixFold is the in-memory equivalent of ILIKE 'prefix%'.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// queryPage runs spec's SELECT with the filters in b and returns one keyset
+// page. It fetches one extra row to know whether another page exists.
+func queryPage[T any](ctx context.Context, db *sql.DB, spec listSpec[T], b queryBuilder, req PageRequest, scan func(scanner) (T, error)) (Page[T], error) {
+	page, err := spec.resolve(req)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	direction, comparison := "ASC", ">"
+	if req.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if page.cursor != nil {
+		b.where("("+page.field.column+", "+spec.idColumn+") "+comparison+" (?::"+page.field.cast+", ?::"+spec.idCast+")",
+			page.cursor.Value, page.cursor.ID)
+	}
+
+	b.args = append(b.args, page.limit+1)
+	query := spec.selectSQL + b.whereSQL() +
+		" ORDER BY " + page.field.column + " " + direction + ", " + spec.idColumn + " " + direction +
+		" LIMIT $" + strconv.Itoa(len(b.args))
+
+	rows, err := db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return Page[T]{}, err
+	}
+
+	return page.next(spec, items, req.Desc), nil
+}
+
+// memoryPage is queryPage for the in-memory stores; items must already be
+// filtered. The cursor's ID locates the last row of the previous page.
+func memoryPage[T any](items []T, spec listSpec[T], req PageRequest) (Page[T], error) {
+	page, err := spec.resolve(req)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if req.Desc {
+			a, b = b, a
+		}
+		if page.field.less(a, b) {
+			return true
+		}
+		if page.field.less(b, a) {
+			return false
+		}
+		return spec.id(a) < spec.id(b)
+	})
+
+	if page.cursor != nil {
+		start := len(items)
+		for i, item := range items {
+			if spec.id(item) == page.cursor.ID {
+				start = i + 1
+				break
+			}
+		}
+		items = items[start:]
+	}
+
+	if len(items) > page.limit+1 {
+		items = items[:page.limit+1]
+	}
+	return page.next(spec, items, req.Desc), nil
+}
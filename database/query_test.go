@@ -0,0 +1,21 @@
+package database
+
+import "testing"
+
+func TestPageLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 0, want: DefaultPageLimit},
+		{limit: -1, want: DefaultPageLimit},
+		{limit: 1, want: 1},
+		{limit: MaxPageLimit, want: MaxPageLimit},
+		{limit: MaxPageLimit + 1, want: MaxPageLimit},
+	}
+	for _, tt := range tests {
+		if got := pageLimit(tt.limit); got != tt.want {
+			t.Errorf("pageLimit(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+)
+
+// RateLimit is a token bucket: it holds up to Burst requests and refills at
+// Rate requests per second.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func PerMinute(n int, burst int) RateLimit {
+	return RateLimit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// RateDecision is the outcome of one RateLimiter.Allow call.
+type RateDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next request would be allowed; zero
+	// when Allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// RateLimiter takes one token from key's bucket if it has one.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (RateDecision, error)
+	// Prune forgets every bucket that has refilled under its own limit; a
+	// full bucket behaves exactly like a missing one.
+	Prune(ctx context.Context) error
+}
+
+// decide turns the tokens left in a bucket after a request into a decision.
+func decide(limit RateLimit, tokens float64, allowed bool) RateDecision {
+	decision := RateDecision{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		decision.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	return decision
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+type pgRateLimiter struct {
+	db *sql.DB
+}
+
+// NewPostgresRateLimiter keeps buckets in the rate_limits table so every
+// replica shares them.
+func NewPostgresRateLimiter(db *sql.DB) RateLimiter {
+	return &pgRateLimiter{db: db}
+}
+
+// Allow refills and drains the bucket in a single upsert. Every SET
+// expression sees the row as it was before the update. The row keeps its
+// limit so Prune can tell when it is full.
+func (l *pgRateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (RateDecision, error) {
+	defer observe("rate_limits", "Allow", time.Now())
+	query := `INSERT INTO rate_limits (key, tokens, allowed, rate, burst, updated_at) VALUES ($1, $2::float8 - 1, true, $3, $2, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			rate = $3,
+			burst = $2,
+			allowed = LEAST($2::float8, rate_limits.tokens + EXTRACT(EPOCH FROM NOW() - rate_limits.updated_at)::float8 * $3::float8) >= 1,
+			tokens = LEAST($2::float8, rate_limits.tokens + EXTRACT(EPOCH FROM NOW() - rate_limits.updated_at)::float8 * $3::float8)
+				- CASE WHEN LEAST($2::float8, rate_limits.tokens + EXTRACT(EPOCH FROM NOW() - rate_limits.updated_at)::float8 * $3::float8) >= 1 THEN 1 ELSE 0 END,
+			updated_at = NOW()
+		RETURNING tokens, allowed`
+
+	var tokens float64
+	var allowed bool
+	err := l.db.QueryRowContext(ctx, query, key, float64(limit.Burst), limit.Rate).Scan(&tokens, &allowed)
+	if err != nil {
+		return RateDecision{}, err
+	}
+	return decide(limit, tokens, allowed), nil
+}
+
+func (l *pgRateLimiter) Prune(ctx context.Context) error {
+	defer observe("rate_limits", "Prune", time.Now())
+	query := `DELETE FROM rate_limits
+		WHERE tokens + EXTRACT(EPOCH FROM NOW() - updated_at)::float8 * rate >= burst`
+	_, err := l.db.ExecContext(ctx, query)
+	return err
+}
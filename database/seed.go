@@ -30,7 +30,7 @@ func SetFakeUsers(count int, db *sql.DB) error {
 			return err
 		}
 
-		query := "INSERT INTO users (id, name, email, password, avatar, online) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at"
+		query := "INSERT INTO users (id, name, email, password, avatar, online, verified) VALUES ($1, $2, $3, $4, $5, $6, true) RETURNING created_at"
 		err = db.QueryRow(query, newUser.ID, newUser.Name, newUser.Email, hashedPassword, newUser.Avatar, newUser.Online).Scan(&newUser.CreatedAt)
 		if err != nil {
 			return err
@@ -48,7 +48,7 @@ func SetFakeProducts(count int, db *sql.DB) error {
 			UPC:         generateFakeUPC(existingUPCs),
 			Name:        gofakeit.ProductName(),
 			Description: gofakeit.Paragraph(1, 3, 5, " "),
-			Price:       gofakeit.Price(10, 1000), // Random price between 10 and 1000
+			Price:       NewMoney(int64(gofakeit.Number(1000, 100000)), DefaultCurrency), // Random price between 10.00 and 1000.00
 			Images: []string{
 				gofakeit.ImageURL(600, 600),
 				gofakeit.ImageURL(600, 600),
@@ -58,14 +58,15 @@ func SetFakeProducts(count int, db *sql.DB) error {
 			UpdatedAt: time.Now().Format(time.RFC3339),
 		}
 
-		query := `INSERT INTO products (upc, name, description, price, images, created_at, updated_at)
-				  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		query := `INSERT INTO products (upc, name, description, price_minor, currency, images, created_at, updated_at)
+				  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 		_, err := db.Exec(
 			query,
 			newProduct.UPC,
 			newProduct.Name,
 			newProduct.Description,
-			newProduct.Price,
+			newProduct.Price.Amount,
+			newProduct.Price.Currency,
 			pq.Array(newProduct.Images),
 			newProduct.CreatedAt,
 			newProduct.UpdatedAt,
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Stores return ErrNotFound and ErrConflict (wrapped with the entity name)
+// rather than driver errors, so callers never need to know about SQL.
+
+// Zero-valued filter fields match everything.
+
+type UserFilter struct {
+	NamePrefix string
+}
+
+type ProductFilter struct {
+	NamePrefix string
+	MinPrice   *Money
+	MaxPrice   *Money
+}
+
+type OrderFilter struct {
+	Status        OrderStatus
+	User          string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+type ChatFilter struct {
+	Member string
+}
+
+type UserStore interface {
+	Create(ctx context.Context, user User) error
+	GetByID(ctx context.Context, id string) (User, error)
+	GetByEmail(ctx context.Context, email string) (User, error)
+	List(ctx context.Context, filter UserFilter, page PageRequest) (Page[User], error)
+	// Update saves user's profile fields. It never touches the password;
+	// use UpdatePassword with an already hashed password for that. A new
+	// email is unverified until MarkVerified.
+	Update(ctx context.Context, user User) error
+	UpdatePassword(ctx context.Context, id string, hash string) error
+	MarkVerified(ctx context.Context, id string) error
+	UpdateRole(ctx context.Context, id string, role Role) error
+	Delete(ctx context.Context, id string) error
+}
+
+type ProductStore interface {
+	Create(ctx context.Context, product Product) error
+	Get(ctx context.Context, upc string) (Product, error)
+	List(ctx context.Context, filter ProductFilter, page PageRequest) (Page[Product], error)
+	Update(ctx context.Context, product Product) error
+	Delete(ctx context.Context, upc string) error
+}
+
+type OrderStore interface {
+	// Create prices order.Items from the current product catalogue, computes
+	// the total and returns the stored order. Unknown UPCs yield ErrInvalid.
+	Create(ctx context.Context, order Order) (Order, error)
+	Get(ctx context.Context, orderNumber int) (Order, error)
+	List(ctx context.Context, filter OrderFilter, page PageRequest) (Page[Order], error)
+	// Update reassigns an order's owner. Status changes go through
+	// UpdateStatus.
+	Update(ctx context.Context, order Order) error
+	// UpdateStatus moves an order along the lifecycle and records the change.
+	// Illegal transitions yield ErrConflict.
+	UpdateStatus(ctx context.Context, orderNumber int, status OrderStatus, changedBy string) (Order, error)
+	History(ctx context.Context, orderNumber int) ([]OrderStatusChange, error)
+	// Delete removes an order that is still NotSent. Orders that have moved
+	// on yield ErrConflict so their history is kept; cancel them instead.
+	Delete(ctx context.Context, orderNumber int) error
+}
+
+type ChatStore interface {
+	CreateChat(ctx context.Context, chat Chat) error
+	GetChat(ctx context.Context, chatID string) (Chat, error)
+	ListChats(ctx context.Context, filter ChatFilter, page PageRequest) (Page[Chat], error)
+	// ListChatSummaries lists chats with their latest message, ordered by
+	// last activity.
+	ListChatSummaries(ctx context.Context, filter ChatFilter, page PageRequest) (Page[ChatSummary], error)
+	DeleteChat(ctx context.Context, chatID string) error
+	IsMember(ctx context.Context, chatID string, userID string) (bool, error)
+	// SetMember adds userID to the chat with role, or changes the role of an
+	// existing member. It never changes the owner; role must be ChatAdmin or
+	// ChatMember.
+	SetMember(ctx context.Context, chatID string, userID string, role ChatRole) error
+	RemoveMember(ctx context.Context, chatID string, userID string) error
+
+	// MarkRead moves userID's read position in chatID forward to messageID,
+	// or to the latest message if messageID is empty, and returns the
+	// resulting position. It never moves it back.
+	MarkRead(ctx context.Context, chatID string, userID string, messageID string) (ReadReceipt, error)
+	// UnreadCounts returns, for each of chatIDs userID is a member of, how
+	// many messages from others are past their read position.
+	UnreadCounts(ctx context.Context, userID string, chatIDs []string) (map[string]int, error)
+	// ReadReceipts returns the read position of every member of chatID who
+	// has read anything.
+	ReadReceipts(ctx context.Context, chatID string) ([]ReadReceipt, error)
+
+	CreateMessage(ctx context.Context, msg Message) error
+	GetMessage(ctx context.Context, messageID string) (Message, error)
+	ListMessages(ctx context.Context, chatID string, req MessagePageRequest) (MessagePage, error)
+	// EditMessage replaces a message's text and media, keeping the old
+	// version in its edit history.
+	EditMessage(ctx context.Context, messageID string, text string, media []string) (Message, error)
+	// MessageEdits lists a message's earlier versions, oldest first.
+	MessageEdits(ctx context.Context, messageID string) ([]MessageEdit, error)
+	// DeleteMessage turns a message into a tombstone, dropping its content,
+	// edit history and reactions. Deleting a tombstone does nothing.
+	DeleteMessage(ctx context.Context, messageID string) error
+	// AddReaction records userID reacting with emoji. Reacting twice with the
+	// same emoji does nothing.
+	AddReaction(ctx context.Context, messageID string, userID string, emoji string) error
+	RemoveReaction(ctx context.Context, messageID string, userID string, emoji string) error
+}
+
+type TokenStore interface {
+	CreateRefreshToken(ctx context.Context, token RefreshToken) error
+	// RotateRefreshToken marks tokenID as used and stores next in its family,
+	// all or nothing. tokenID must belong to userID. If it was already used,
+	// its whole family is revoked and ErrRefreshTokenReused is returned.
+	RotateRefreshToken(ctx context.Context, tokenID string, userID string, next RefreshToken) error
+	// RevokeFamily revokes the family tokenID belongs to if userID owns it.
+	RevokeFamily(ctx context.Context, tokenID string, userID string) error
+	// RevokeUserTokens revokes every refresh token userID holds.
+	RevokeUserTokens(ctx context.Context, userID string) error
+
+	CreatePasswordReset(ctx context.Context, reset PasswordReset) error
+	// ConsumePasswordReset marks an unused, unexpired reset as used and
+	// returns it, or returns ErrResetTokenInvalid.
+	ConsumePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error)
+
+	CreateEmailVerification(ctx context.Context, verification EmailVerification) error
+	// ConsumeEmailVerification marks an unused, unexpired verification as
+	// used and returns it, or returns ErrVerificationTokenInvalid.
+	ConsumeEmailVerification(ctx context.Context, tokenHash string) (EmailVerification, error)
+	// LastEmailVerification returns when userID was last sent a
+	// verification token, or the zero time if never.
+	LastEmailVerification(ctx context.Context, userID string) (time.Time, error)
+}
+
+// LoginStore tracks failed logins. Keys are an email or an IP address, see
+// Authenticate.
+type LoginStore interface {
+	// LockedUntil returns the latest lockout among keys.
+	LockedUntil(ctx context.Context, keys []string) (time.Time, error)
+	// AddFailure counts a failure against key and returns how many it has
+	// had, forgetting earlier failures more than window old.
+	AddFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	// Prune forgets keys whose last failure is more than window old and
+	// that are not locked, and audit rows more than retention old.
+	Prune(ctx context.Context, window time.Duration, retention time.Duration) error
+
+	AuditFailure(ctx context.Context, failure LoginFailure) error
+	ListFailures(ctx context.Context, filter LoginFailureFilter, page PageRequest) (Page[LoginFailure], error)
+}
+
+type Stores struct {
+	Users    UserStore
+	Products ProductStore
+	Orders   OrderStore
+	Chats    ChatStore
+	Tokens   TokenStore
+	Logins   LoginStore
+}
+
+func NewPostgresStores(db *sql.DB) *Stores {
+	return &Stores{
+		Users:    NewPostgresUserStore(db),
+		Products: NewPostgresProductStore(db),
+		Orders:   NewPostgresOrderStore(db),
+		Chats:    NewPostgresChatStore(db),
+		Tokens:   NewPostgresTokenStore(db),
+		Logins:   NewPostgresLoginStore(db),
+	}
+}
+
+func NewMemoryStores() *Stores {
+	products := NewMemoryProductStore()
+	return &Stores{
+		Users:    NewMemoryUserStore(),
+		Products: products,
+		Orders:   NewMemoryOrderStore(products),
+		Chats:    NewMemoryChatStore(),
+		Tokens:   NewMemoryTokenStore(),
+		Logins:   NewMemoryLoginStore(),
+	}
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/config"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+type RefreshToken struct {
+	TokenID   string
+	FamilyID  string
+	UserID    string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+func newUserClaims(auth config.AuthConfig, user User) UserClaims {
+	return UserClaims{
+		ID:    user.ID,
+		Name:  user.Name,
+		Email: user.Email,
+		Role:  user.Role,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   user.ID,
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(auth.AccessTokenTTL).Unix(),
+		},
+	}
+}
+
+// newTokenPair signs an access token and a refresh token for user. The
+// returned RefreshToken is the record to store for the refresh token; its
+// FamilyID is left for the caller to set.
+func newTokenPair(auth config.AuthConfig, user User) (TokenPair, RefreshToken, error) {
+	var pair TokenPair
+
+	token, err := NewAccessToken(auth, newUserClaims(auth, user))
+	if err != nil {
+		return pair, RefreshToken{}, err
+	}
+
+	tokenID, err := uuid.NewV4()
+	if err != nil {
+		return pair, RefreshToken{}, err
+	}
+	expiresAt := time.Now().Add(auth.RefreshTokenTTL)
+
+	refreshToken, err := NewRefreshToken(auth, jwt.StandardClaims{
+		Id:        tokenID.String(),
+		Subject:   user.ID,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return pair, RefreshToken{}, err
+	}
+
+	pair.Token = token
+	pair.RefreshToken = refreshToken
+	return pair, RefreshToken{TokenID: tokenID.String(), UserID: user.ID, ExpiresAt: expiresAt}, nil
+}
+
+// NewSession starts a new refresh token family for user, e.g. on login.
+func NewSession(ctx context.Context, auth config.AuthConfig, tokens TokenStore, user User) (TokenPair, error) {
+	familyID, err := uuid.NewV4()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	pair, record, err := newTokenPair(auth, user)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	record.FamilyID = familyID.String()
+	if err := tokens.CreateRefreshToken(ctx, record); err != nil {
+		return TokenPair{}, err
+	}
+	return pair, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new token pair in the
+// same family. Each refresh token is single use: presenting one that has
+// already been exchanged revokes the whole family. The new pair is prepared
+// before the old token is touched, so a failure leaves the old token usable.
+func RotateRefreshToken(ctx context.Context, auth config.AuthConfig, tokens TokenStore, users UserStore, claims *jwt.StandardClaims) (TokenPair, error) {
+	user, err := users.GetByID(ctx, claims.Subject)
+	if errors.Is(err, ErrNotFound) {
+		return TokenPair{}, ErrRefreshTokenInvalid
+	} else if err != nil {
+		return TokenPair{}, err
+	}
+
+	pair, next, err := newTokenPair(auth, user)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if err := tokens.RotateRefreshToken(ctx, claims.Id, user.ID, next); err != nil {
+		return TokenPair{}, err
+	}
+	return pair, nil
+}
+
+type pgTokenStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTokenStore(db *sql.DB) TokenStore {
+	return &pgTokenStore{db: db}
+}
+
+func (s *pgTokenStore) CreateRefreshToken(ctx context.Context, token RefreshToken) error {
+	defer observe("tokens", "CreateRefreshToken", time.Now())
+	query := `INSERT INTO refresh_tokens (token_id, family_id, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`
+	_, err := s.db.ExecContext(ctx, query, token.TokenID, token.FamilyID, token.UserID, token.ExpiresAt)
+	return mapError(err, "Refresh token")
+}
+
+func (s *pgTokenStore) RotateRefreshToken(ctx context.Context, tokenID string, userID string, next RefreshToken) error {
+	defer observe("tokens", "RotateRefreshToken", time.Now())
+	var token RefreshToken
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT family_id, user_id, expires_at, used_at, revoked_at FROM refresh_tokens WHERE token_id = $1 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, tokenID).Scan(&token.FamilyID, &token.UserID, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt)
+	if err == sql.ErrNoRows {
+		return ErrRefreshTokenInvalid
+	} else if err != nil {
+		return err
+	}
+
+	if token.UserID != userID || token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return ErrRefreshTokenInvalid
+	}
+
+	if token.UsedAt != nil {
+		if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`, token.FamilyID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrRefreshTokenReused
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = NOW() WHERE token_id = $1`, tokenID); err != nil {
+		return err
+	}
+	insert := `INSERT INTO refresh_tokens (token_id, family_id, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())`
+	if _, err := tx.ExecContext(ctx, insert, next.TokenID, token.FamilyID, next.UserID, next.ExpiresAt); err != nil {
+		return mapError(err, "Refresh token")
+	}
+
+	return tx.Commit()
+}
+
+func (s *pgTokenStore) RevokeFamily(ctx context.Context, tokenID string, userID string) error {
+	defer observe("tokens", "RevokeFamily", time.Now())
+	query := `UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE revoked_at IS NULL
+		AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_id = $1 AND user_id = $2)`
+	result, err := s.db.ExecContext(ctx, query, tokenID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRefreshTokenInvalid
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/config"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var testAuth = config.AuthConfig{
+	TokenSecret:        "test access secret",
+	RefreshTokenSecret: "test refresh secret",
+	AccessTokenTTL:     time.Minute,
+	RefreshTokenTTL:    time.Hour,
+}
+
+func refreshClaims(t *testing.T, pair TokenPair) *jwt.StandardClaims {
+	t.Helper()
+	claims := ParseRefreshToken(testAuth, pair.RefreshToken)
+	if claims == nil {
+		t.Fatal("refresh token does not parse")
+	}
+	return claims
+}
+
+// TestRotateRefreshToken rotates a session once, then presents claims
+// derived from its first or second token. Afterwards the newest token
+// must still rotate unless the family was revoked.
+func TestRotateRefreshToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    func(first, second jwt.StandardClaims) jwt.StandardClaims
+		wantErr   error
+		wantAfter error
+	}{
+		{
+			name:   "latest token",
+			claims: func(first, second jwt.StandardClaims) jwt.StandardClaims { return second },
+		},
+		{
+			name:      "reused token revokes the family",
+			claims:    func(first, second jwt.StandardClaims) jwt.StandardClaims { return first },
+			wantErr:   ErrRefreshTokenReused,
+			wantAfter: ErrRefreshTokenInvalid,
+		},
+		{
+			name: "another user's subject",
+			claims: func(first, second jwt.StandardClaims) jwt.StandardClaims {
+				second.Subject = "user-2"
+				return second
+			},
+			wantErr: ErrRefreshTokenInvalid,
+		},
+		{
+			name: "deleted user",
+			claims: func(first, second jwt.StandardClaims) jwt.StandardClaims {
+				second.Subject = "user-3"
+				return second
+			},
+			wantErr: ErrRefreshTokenInvalid,
+		},
+		{
+			name: "unknown token",
+			claims: func(first, second jwt.StandardClaims) jwt.StandardClaims {
+				second.Id = "not-a-token"
+				return second
+			},
+			wantErr: ErrRefreshTokenInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stores := NewMemoryStores()
+			for _, user := range []User{{ID: "user-1", Name: "Ada", Email: "ada@example.com"}, {ID: "user-2", Name: "Grace", Email: "grace@example.com"}} {
+				if err := stores.Users.Create(ctx, user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			user, _ := stores.Users.GetByID(ctx, "user-1")
+			pair, err := NewSession(ctx, testAuth, stores.Tokens, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := refreshClaims(t, pair)
+			pair, err = RotateRefreshToken(ctx, testAuth, stores.Tokens, stores.Users, first)
+			if err != nil {
+				t.Fatal(err)
+			}
+			newest := refreshClaims(t, pair)
+
+			claims := tt.claims(*first, *newest)
+			pair, err = RotateRefreshToken(ctx, testAuth, stores.Tokens, stores.Users, &claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RotateRefreshToken error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if ParseAccessToken(testAuth, pair.Token) == nil {
+					t.Error("rotation returned an invalid access token")
+				}
+				newest = refreshClaims(t, pair)
+			}
+
+			if _, err := RotateRefreshToken(ctx, testAuth, stores.Tokens, stores.Users, newest); !errors.Is(err, tt.wantAfter) {
+				t.Errorf("rotating the newest token afterwards error = %v, want %v", err, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestRevokeFamily(t *testing.T) {
+	ctx := context.Background()
+	stores := NewMemoryStores()
+	user := User{ID: "user-1", Name: "Ada", Email: "ada@example.com"}
+	if err := stores.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := NewSession(ctx, testAuth, stores.Tokens, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewSession(ctx, testAuth, stores.Tokens, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := refreshClaims(t, pair)
+
+	if err := stores.Tokens.RevokeFamily(ctx, claims.Id, "user-2"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("revoking another user's family error = %v, want ErrRefreshTokenInvalid", err)
+	}
+	if err := stores.Tokens.RevokeFamily(ctx, claims.Id, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RotateRefreshToken(ctx, testAuth, stores.Tokens, stores.Users, claims); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("rotating a revoked token error = %v, want ErrRefreshTokenInvalid", err)
+	}
+	if _, err := RotateRefreshToken(ctx, testAuth, stores.Tokens, stores.Users, refreshClaims(t, other)); err != nil {
+		t.Errorf("rotating a token from another session: %v", err)
+	}
+}
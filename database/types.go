@@ -1,14 +1,38 @@
 package database
 
-import "time"
+import (
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleStaff    Role = "staff"
+	RoleAdmin    Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleCustomer, RoleStaff, RoleAdmin:
+		return true
+	}
+	return false
+}
 
 type User struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password"`
+	Password  string    `json:"-"` // bcrypt hash; never serialised
 	Avatar    string    `json:"avatar"`
 	Online    bool      `json:"online"`
+	Verified  bool      `json:"verified"`
+	Role      Role      `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -17,7 +41,7 @@ type Product struct {
 	UPC         string   `json:"upc"`
 	Name        string   `json:"name"`
 	Description string   `json:"description"`
-	Price       float64  `json:"price"`
+	Price       Money    `json:"price"`
 	Images      []string `json:"images"`
 	CreatedAt   string   `json:"created_at"`
 	UpdatedAt   string   `json:"updated_at"`
@@ -32,31 +56,229 @@ const (
 	InProgress OrderStatus = "In Progress"
 	InTransit  OrderStatus = "In Transit"
 	Delivered  OrderStatus = "Delivered"
+	Cancelled  OrderStatus = "Cancelled"
+	Refunded   OrderStatus = "Refunded"
 )
 
+// orderTransitions is the order lifecycle: each status lists the statuses it
+// may move to next. Refunded is terminal.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	NotSent:    {Sent, Cancelled},
+	Sent:       {Received, Cancelled},
+	Received:   {InProgress, Cancelled},
+	InProgress: {InTransit, Cancelled},
+	InTransit:  {Delivered},
+	Delivered:  {Refunded},
+	Cancelled:  {Refunded},
+	Refunded:   {},
+}
+
+func (s OrderStatus) Valid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
 	OrderNumber int         `json:"orderNumber"`
 	Status      OrderStatus `json:"status"`
 	User        string      `json:"user"`
-	Products    []string    `json:"products"`
-	Total       float64     `json:"total"`
+	Items       []OrderItem `json:"items"`
+	Total       Money       `json:"total"`
 	CreatedAt   string      `json:"createdAt"`
 	UpdatedAt   string      `json:"updatedAt"`
 }
 
+type OrderStatusChange struct {
+	OrderNumber int         `json:"orderNumber"`
+	FromStatus  OrderStatus `json:"fromStatus"`
+	ToStatus    OrderStatus `json:"toStatus"`
+	ChangedBy   string      `json:"changedBy"`
+	ChangedAt   time.Time   `json:"changedAt"`
+}
+
+// OrderItem is one line of an order. UnitPrice is the product price at the
+// time the order was placed.
+type OrderItem struct {
+	UPC       string `json:"upc"`
+	Name      string `json:"name,omitempty"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Money  `json:"unitPrice"`
+	LineTotal Money  `json:"lineTotal"`
+}
+
+// ChatRole is a member's role within one chat, separate from their
+// site-wide Role. The owner created the chat; owners and admins manage its
+// members.
+type ChatRole string
+
+const (
+	ChatOwner  ChatRole = "owner"
+	ChatAdmin  ChatRole = "admin"
+	ChatMember ChatRole = "member"
+)
+
+func (r ChatRole) Valid() bool {
+	switch r {
+	case ChatOwner, ChatAdmin, ChatMember:
+		return true
+	}
+	return false
+}
+
+// CanManage reports whether the role may add and remove members.
+func (r ChatRole) CanManage() bool {
+	return r == ChatOwner || r == ChatAdmin
+}
+
 type Chat struct {
 	ChatID    string    `json:"chat_id"`
+	Owner     string    `json:"owner"`
+	Admins    []string  `json:"admins"`
 	Users     []string  `json:"users"`
-	Messages  []string  `json:"messages"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// ChatSummary is a chat as shown in a chat list: with its most recent
+// message, text shortened to ChatPreviewLength, and when it was last active.
+type ChatSummary struct {
+	Chat
+	LastMessage    *Message  `json:"last_message"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+}
+
+// RoleOf returns userID's role in the chat, or false if they are not a
+// member.
+func (c Chat) RoleOf(userID string) (ChatRole, bool) {
+	switch {
+	case !slices.Contains(c.Users, userID):
+		return "", false
+	case c.Owner == userID:
+		return ChatOwner, true
+	case slices.Contains(c.Admins, userID):
+		return ChatAdmin, true
+	default:
+		return ChatMember, true
+	}
+}
+
+// Message is one message in a chat. A deleted message stays in the
+// conversation as a tombstone: DeletedAt is set and its text, media and
+// reactions are gone.
 type Message struct {
-	MessageID string    `json:"message_id"`
-	Chat      string    `json:"chat"`
-	Sender    string    `json:"sender"`
-	Text      string    `json:"text"`
-	Media     []string  `json:"media"`
-	CreatedAt time.Time `json:"created_at"`
+	MessageID string          `json:"message_id"`
+	Chat      string          `json:"chat"`
+	Sender    string          `json:"sender"`
+	Text      string          `json:"text"`
+	Media     []string        `json:"media"`
+	Reactions []ReactionCount `json:"reactions"`
+	CreatedAt time.Time       `json:"created_at"`
+	EditedAt  *time.Time      `json:"edited_at"`
+	DeletedAt *time.Time      `json:"deleted_at,omitempty"`
+}
+
+// Deleted reports whether the message is a tombstone.
+func (m Message) Deleted() bool {
+	return m.DeletedAt != nil
+}
+
+// MessageEdit is an earlier version of a message, kept when it was edited.
+// EditedAt is when this version was replaced.
+type MessageEdit struct {
+	Text     string    `json:"text"`
+	Media    []string  `json:"media"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// ReactionCount is how many members reacted to a message with Emoji.
+// Messages list them in the order each emoji was first used.
+type ReactionCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+// MaxReactionLength bounds a reaction in bytes; it leaves room for emoji
+// built from several code points, such as flags and ZWJ sequences.
+const MaxReactionLength = 32
+
+// emojiRunes holds the code points that render as emoji on their own. It
+// is deliberately coarse: whole blocks rather than the exact Emoji property.
+var emojiRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00a9, Hi: 0x00ae, Stride: 5}, // © ®
+		{Lo: 0x203c, Hi: 0x203c, Stride: 1}, // ‼
+		{Lo: 0x2049, Hi: 0x2049, Stride: 1}, // ⁉
+		{Lo: 0x2122, Hi: 0x2139, Stride: 0x17},
+		{Lo: 0x2194, Hi: 0x21aa, Stride: 1},
+		{Lo: 0x231a, Hi: 0x23ff, Stride: 1},
+		{Lo: 0x24c2, Hi: 0x24c2, Stride: 1},
+		{Lo: 0x25aa, Hi: 0x27bf, Stride: 1},
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+		{Lo: 0x2b05, Hi: 0x2b55, Stride: 1},
+		{Lo: 0x3030, Hi: 0x303d, Stride: 0xd},
+		{Lo: 0x3297, Hi: 0x3299, Stride: 2},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1faff, Stride: 1},
+	},
+	LatinOffset: 1,
+}
+
+// Code points that only combine with an emoji: zero width joiner, variation
+// selector 16, the keycap mark and the tags used by subdivision flags.
+const (
+	zeroWidthJoiner = '\u200d'
+	emojiVariation  = '\ufe0f'
+	combiningKeycap = '\u20e3'
+)
+
+func isEmojiModifier(r rune) bool {
+	return r == zeroWidthJoiner || r == emojiVariation || r == combiningKeycap || (r >= 0xe0020 && r <= 0xe007f)
+}
+
+// ValidReaction reports whether emoji is acceptable as a reaction: a short
+// sequence of emoji code points and the marks that join them. Digits, # and
+// * are only allowed as the base of a keycap such as 1️⃣.
+func ValidReaction(emoji string) bool {
+	if emoji == "" || len(emoji) > MaxReactionLength || !utf8.ValidString(emoji) {
+		return false
+	}
+	keycap := strings.ContainsRune(emoji, combiningKeycap)
+	hasEmoji := false
+	for _, r := range emoji {
+		switch {
+		case unicode.Is(emojiRunes, r):
+			hasEmoji = true
+		case keycap && (r == '#' || r == '*' || (r >= '0' && r <= '9')):
+			hasEmoji = true
+		case isEmojiModifier(r):
+		default:
+			return false
+		}
+	}
+	return hasEmoji
+}
+
+// ReadReceipt is how far a member has read a chat. Messages are ordered by
+// created_at, then message ID, so LastReadAt is the read message's
+// created_at.
+type ReadReceipt struct {
+	UserID            string    `json:"user_id"`
+	LastReadMessageID string    `json:"last_read_message_id"`
+	LastReadAt        time.Time `json:"last_read_at"`
+}
+
+// HasRead reports whether msg is at or before the member's read position.
+func (r ReadReceipt) HasRead(msg Message) bool {
+	return msg.CreatedAt.Before(r.LastReadAt) ||
+		(msg.CreatedAt.Equal(r.LastReadAt) && msg.MessageID <= r.LastReadMessageID)
 }
@@ -1,273 +1,146 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"net/http"
+	"strings"
 	"time"
-
-	"github.com/gin-gonic/gin"
-	"github.com/gofrs/uuid/v5"
-	"github.com/golang-jwt/jwt"
 )
 
-func CreateUsersTable(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		id TEXT UNIQUE NOT NULL PRIMARY KEY,
-		name TEXT UNIQUE NOT NULL,
-		email TEXT UNIQUE NOT NULL,
-		password TEXT NOT NULL,
-		avatar TEXT,
-		online BOOL DEFAULT false,
-		created_at TIMESTAMP DEFAULT NOW(),
-		updated_at TIMESTAMP DEFAULT NOW()
-	);`
-
-	_, err := db.Exec(query)
-	return err
-}
-
+// SeedNewUsers inserts count fake users. The schema must already be
+// migrated.
 func SeedNewUsers(count int, db *sql.DB) error {
-	err := CreateUsersTable(db)
-	if err != nil {
-		return err
-	}
-	err = SetFakeUsers(count, db)
-	return err
+	return SetFakeUsers(count, db)
 }
 
-func CreateUser(db *sql.DB, c *gin.Context) {
-	var user User
-	id, err := uuid.NewV1()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	user.ID = "user_" + id.String()
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// ⚡️ Hash the password before inserting
-	hashedPassword, err := HashedPassword(user.Password)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
-		return
-	}
-
-	query := `INSERT INTO users (id, name, email, password, avatar, online, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())`
-	_, err = db.ExecContext(c, query, user.ID, user.Name, user.Email, hashedPassword, user.Avatar, user.Online)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+const userColumns = `id, name, email, password, avatar, online, verified, role, created_at, updated_at`
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User created!"})
+// NormalizeEmail is the form emails are stored and looked up in, so that
+// addresses differing only by case belong to one account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
-type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+type scanner interface {
+	Scan(dest ...any) error
 }
 
-func Login(db *sql.DB, c *gin.Context) {
-	var req LoginRequest
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-		return
-	}
-
+func scanUser(row scanner) (User, error) {
 	var user User
-	query := `SELECT id, name, email, password, avatar, online, created_at, updated_at FROM users WHERE email = $1`
-	err := db.QueryRowContext(c, query, req.Email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Avatar, &user.Online, &user.CreatedAt, &user.UpdatedAt)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if !CheckPasswordHash(req.Password, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password Verification Failed"})
-		return
-	}
-
-	userClaims := UserClaims{
-		ID:    user.ID,
-		Name:  user.Name,
-		Email: user.Email,
-		StandardClaims: jwt.StandardClaims{
-			IssuedAt:  time.Now().Unix(),
-			ExpiresAt: time.Now().Add(15 * time.Minute).Unix(),
-		},
-	}
-
-	token, err := NewAccessToken(userClaims)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
-		return
-	}
-
-	refreshToken, err := NewRefreshToken(userClaims.StandardClaims)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message":      "Login Success",
-		"token":        token,
-		"refreshToken": refreshToken,
-		"user":         user,
-	})
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Avatar, &user.Online, &user.Verified, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	return user, err
 }
 
-func RefreshTokenHandler(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		const userIDKey ContextKey = "userID"
-
-		id, ok := c.Request.Context().Value(userIDKey).(string)
-		if !ok || id == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "ID not found in context"})
-			return
-		}
-
-		var user User
-		query := `SELECT id, name, email, password, avatar, online, created_at, updated_at FROM users WHERE id = $1`
-		err := db.QueryRowContext(c, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Avatar, &user.Online, &user.CreatedAt, &user.UpdatedAt)
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		userClaims := UserClaims{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Email,
-			StandardClaims: jwt.StandardClaims{
-				IssuedAt:  time.Now().Unix(),
-				ExpiresAt: time.Now().Add(15 * time.Minute).Unix(),
-			},
-		}
-
-		token, err := NewAccessToken(userClaims)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
-			return
-		}
-
-		refreshToken, err := NewRefreshToken(userClaims.StandardClaims)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
-			return
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"message":      "Token Refreshed",
-			"token":        token,
-			"refreshToken": refreshToken,
-		})
-	}
+type pgUserStore struct {
+	db *sql.DB
 }
 
-func GetUsers(db *sql.DB, c *gin.Context) {
-	rows, err := db.QueryContext(c, "SELECT id, name, email, password, avatar, online, created_at, updated_at FROM users;")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	defer rows.Close()
+func NewPostgresUserStore(db *sql.DB) UserStore {
+	return &pgUserStore{db: db}
+}
 
-	var users []User
-	for rows.Next() {
-		var user User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Avatar, &user.Online, &user.CreatedAt, &user.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		users = append(users, user)
-	}
+// Create inserts user as given; user.Password must already be hashed.
+func (s *pgUserStore) Create(ctx context.Context, user User) error {
+	defer observe("users", "Create", time.Now())
+	query := `INSERT INTO users (id, name, email, password, avatar, online, verified, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())`
+	_, err := s.db.ExecContext(ctx, query, user.ID, user.Name, NormalizeEmail(user.Email), user.Password, user.Avatar, user.Online, user.Verified, user.Role)
+	return mapError(err, "User")
+}
 
-	if err := rows.Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+func (s *pgUserStore) getBy(ctx context.Context, column string, value string) (User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + column + ` = $1`
+	user, err := scanUser(s.db.QueryRowContext(ctx, query, value))
+	if err == sql.ErrNoRows {
+		return user, notFound("User")
 	}
+	return user, err
+}
 
-	c.JSON(http.StatusOK, users)
+func (s *pgUserStore) GetByID(ctx context.Context, id string) (User, error) {
+	defer observe("users", "GetByID", time.Now())
+	return s.getBy(ctx, "id", id)
 }
 
-func GetUserByID(db *sql.DB, c *gin.Context) {
-	id := c.Param("id")
-	var user User
-	query := `SELECT id, name, email, password, avatar, online, created_at, updated_at FROM users WHERE id = $1`
-	err := db.QueryRowContext(c, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Avatar, &user.Online, &user.CreatedAt, &user.UpdatedAt)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+func (s *pgUserStore) GetByEmail(ctx context.Context, email string) (User, error) {
+	defer observe("users", "GetByEmail", time.Now())
+	return s.getBy(ctx, "email", NormalizeEmail(email))
+}
 
-	c.JSON(http.StatusOK, user)
+var userList = listSpec[User]{
+	selectSQL: `SELECT ` + userColumns + ` FROM users`,
+	idColumn:  "id",
+	idCast:    "text",
+	id:        func(u User) string { return u.ID },
+	sorts: map[string]sortField[User]{
+		"name": {
+			column: "name", cast: "text",
+			value: func(u User) string { return u.Name },
+			less:  func(a, b User) bool { return a.Name < b.Name },
+		},
+		"created_at": {
+			column: "created_at", cast: "timestamp",
+			value: func(u User) string { return u.CreatedAt.Format(time.RFC3339Nano) },
+			less:  func(a, b User) bool { return a.CreatedAt.Before(b.CreatedAt) },
+		},
+	},
+	defaultSort: "created_at",
 }
 
-func UpdateUserByID(db *sql.DB, c *gin.Context) {
-	id := c.Param("id")
-	var user User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+func (s *pgUserStore) List(ctx context.Context, filter UserFilter, page PageRequest) (Page[User], error) {
+	defer observe("users", "List", time.Now())
+	var b queryBuilder
+	if filter.NamePrefix != "" {
+		b.where("name ILIKE ?", escapeLike(filter.NamePrefix)+"%")
 	}
+	return queryPage(ctx, s.db, userList, b, page, scanUser)
+}
 
-	query := `UPDATE users SET name=$1, email=$2, password=$3, avatar=$4, online=$5, updated_at=NOW() WHERE id=$6`
-	result, err := db.ExecContext(c, query, user.Name, user.Email, user.Password, user.Avatar, user.Online, id)
+func (s *pgUserStore) Update(ctx context.Context, user User) error {
+	defer observe("users", "Update", time.Now())
+	// The right-hand side sees the old row, so a new email clears verified.
+	query := `UPDATE users SET name=$1, email=$2, avatar=$3, online=$4, verified = verified AND email = $2, updated_at=NOW() WHERE id=$5`
+	result, err := s.db.ExecContext(ctx, query, user.Name, NormalizeEmail(user.Email), user.Avatar, user.Online, user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return mapError(err, "User")
 	}
+	return rowsAffectedOrNotFound(result, "User")
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *pgUserStore) UpdatePassword(ctx context.Context, id string, hash string) error {
+	defer observe("users", "UpdatePassword", time.Now())
+	query := `UPDATE users SET password=$1, updated_at=NOW() WHERE id=$2`
+	result, err := s.db.ExecContext(ctx, query, hash, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
+		return err
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "User updated!"})
+	return rowsAffectedOrNotFound(result, "User")
 }
 
-func DeleteUserByID(db *sql.DB, c *gin.Context) {
-	id := c.Param("id")
-	query := `DELETE FROM users WHERE id = $1`
-	result, err := db.ExecContext(c, query, id)
+func (s *pgUserStore) MarkVerified(ctx context.Context, id string) error {
+	defer observe("users", "MarkVerified", time.Now())
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET verified=true, updated_at=NOW() WHERE id=$1`, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
+	return rowsAffectedOrNotFound(result, "User")
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *pgUserStore) UpdateRole(ctx context.Context, id string, role Role) error {
+	defer observe("users", "UpdateRole", time.Now())
+	query := `UPDATE users SET role=$1, updated_at=NOW() WHERE id=$2`
+	result, err := s.db.ExecContext(ctx, query, role, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return err
 	}
+	return rowsAffectedOrNotFound(result, "User")
+}
 
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
+func (s *pgUserStore) Delete(ctx context.Context, id string) error {
+	defer observe("users", "Delete", time.Now())
+	result, err := s.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted!"})
+	return rowsAffectedOrNotFound(result, "User")
 }
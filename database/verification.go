@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	EmailVerificationTTL       = time.Hour * 24
+	VerificationResendInterval = time.Minute
+)
+
+var (
+	ErrVerificationTokenInvalid = errors.New("verification token is invalid or expired")
+	ErrVerificationThrottled    = errors.New("a verification email was sent recently, try again later")
+	ErrEmailNotVerified         = errors.New("email address has not been verified")
+)
+
+// EmailVerification is a pending email confirmation. As with PasswordReset,
+// only the token's hash is stored.
+type EmailVerification struct {
+	TokenHash string
+	UserID    string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// RequestEmailVerification records a new verification for user and returns
+// the token to email them. Requests closer together than
+// VerificationResendInterval fail with ErrVerificationThrottled.
+func RequestEmailVerification(ctx context.Context, tokens TokenStore, user User) (string, error) {
+	last, err := tokens.LastEmailVerification(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if time.Since(last) < VerificationResendInterval {
+		return "", ErrVerificationThrottled
+	}
+	return NewEmailVerification(ctx, tokens, user)
+}
+
+// NewEmailVerification is RequestEmailVerification without the throttle,
+// for when user has just changed their address.
+func NewEmailVerification(ctx context.Context, tokens TokenStore, user User) (string, error) {
+	token, err := newRandomToken()
+	if err != nil {
+		return "", err
+	}
+	err = tokens.CreateEmailVerification(ctx, EmailVerification{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(EmailVerificationTTL),
+	})
+	return token, err
+}
+
+// VerifyEmail consumes a verification token and marks its user verified.
+func VerifyEmail(ctx context.Context, users UserStore, tokens TokenStore, token string) error {
+	verification, err := tokens.ConsumeEmailVerification(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	return users.MarkVerified(ctx, verification.UserID)
+}
+
+func (s *pgTokenStore) CreateEmailVerification(ctx context.Context, verification EmailVerification) error {
+	defer observe("tokens", "CreateEmailVerification", time.Now())
+	query := `INSERT INTO email_verification_tokens (token_hash, user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())`
+	_, err := s.db.ExecContext(ctx, query, verification.TokenHash, verification.UserID, verification.ExpiresAt)
+	return err
+}
+
+func (s *pgTokenStore) ConsumeEmailVerification(ctx context.Context, tokenHash string) (EmailVerification, error) {
+	defer observe("tokens", "ConsumeEmailVerification", time.Now())
+	verification := EmailVerification{TokenHash: tokenHash}
+	query := `UPDATE email_verification_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id, expires_at`
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&verification.UserID, &verification.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return verification, ErrVerificationTokenInvalid
+	}
+	return verification, err
+}
+
+func (s *pgTokenStore) LastEmailVerification(ctx context.Context, userID string) (time.Time, error) {
+	defer observe("tokens", "LastEmailVerification", time.Now())
+	var last sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT MAX(created_at) FROM email_verification_tokens WHERE user_id = $1`, userID).Scan(&last)
+	return last.Time, err
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errChatOwner = newAPIError(http.StatusConflict, "chat_owner", "The chat owner cannot leave or be removed; delete the chat instead")
+
+// chatRole is the caller's role in chat. Site admins act as owners of every
+// chat.
+func chatRole(c *gin.Context, chat database.Chat) (database.ChatRole, bool) {
+	claims := ClaimsFromContext(c)
+	if claims.HasRole(database.RoleAdmin) {
+		return database.ChatOwner, true
+	}
+	return chat.RoleOf(claims.ID)
+}
+
+type AddChatMemberRequest struct {
+	UserID string            `json:"user_id" binding:"required"`
+	Role   database.ChatRole `json:"role" binding:"omitempty,oneof=admin member"`
+}
+
+// AddChatMember adds a user to the chat or changes a member's role. Owners
+// and admins may add members; only the owner may grant or revoke admin.
+func AddChatMember(s *database.Stores, events database.EventBus, c *gin.Context) {
+	var req AddChatMemberRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Role == "" {
+		req.Role = database.ChatMember
+	}
+
+	chat, err := s.Chats.GetChat(c, c.Param("chatID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	actor, _ := chatRole(c, chat)
+	current, isMember := chat.RoleOf(req.UserID)
+	switch {
+	case !actor.CanManage():
+		respondError(c, errForbidden)
+		return
+	case current == database.ChatOwner:
+		respondError(c, errChatOwner)
+		return
+	case actor != database.ChatOwner && (req.Role == database.ChatAdmin || current == database.ChatAdmin):
+		respondError(c, errForbidden)
+		return
+	}
+
+	if _, err := s.Users.GetByID(c, req.UserID); errors.Is(err, database.ErrNotFound) {
+		respondError(c, fieldInvalid("user_id", "exists", "does not match a user"))
+		return
+	} else if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := s.Chats.SetMember(c, chat.ChatID, req.UserID, req.Role); err != nil {
+		respondError(c, err)
+		return
+	}
+	if !isMember {
+		publish(c, events, database.ChatEvent{Type: database.MemberAdded, ChatID: chat.ChatID, UserID: req.UserID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Chat member saved", "user_id": req.UserID, "role": req.Role})
+}
+
+// RemoveChatMember removes :userID from the chat. Members may always leave;
+// owners and admins may remove members, and only the owner may remove an
+// admin.
+func RemoveChatMember(s *database.Stores, events database.EventBus, c *gin.Context) {
+	chat, err := s.Chats.GetChat(c, c.Param("chatID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	userID := c.Param("userID")
+	target, isMember := chat.RoleOf(userID)
+	if !isMember {
+		respondError(c, newAPIError(http.StatusNotFound, "not_found", "Chat member not found"))
+		return
+	}
+	if target == database.ChatOwner {
+		respondError(c, errChatOwner)
+		return
+	}
+
+	leaving := userID == ClaimsFromContext(c).ID
+	actor, _ := chatRole(c, chat)
+	if !leaving && (!actor.CanManage() || (target == database.ChatAdmin && actor != database.ChatOwner)) {
+		respondError(c, errForbidden)
+		return
+	}
+
+	if err := s.Chats.RemoveMember(c, chat.ChatID, userID); err != nil {
+		respondError(c, err)
+		return
+	}
+	publish(c, events, database.ChatEvent{Type: database.MemberRemoved, ChatID: chat.ChatID, UserID: userID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Chat member removed"})
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"fuzzy-succotash-balance/main.go/database"
+)
+
+// Message IDs embed the sender's UUID, so chat users need UUIDs.
+const (
+	ownerID     = "00000000-0000-4000-8000-000000000001"
+	chatAdminID = "00000000-0000-4000-8000-000000000002"
+	memberID    = "00000000-0000-4000-8000-000000000003"
+	outsiderID  = "00000000-0000-4000-8000-000000000004"
+	siteAdminID = "00000000-0000-4000-8000-000000000005"
+	newcomerID  = "00000000-0000-4000-8000-000000000006"
+)
+
+func TestChatMemberPolicies(t *testing.T) {
+	// Every case starts from chat-1 with an owner, a chat admin, a member
+	// and one message from the member.
+	setup := func(t *testing.T) (*testServer, map[string]string, string) {
+		s := newTestServer(t)
+		tokens := map[string]string{
+			"owner":      s.addUser(ownerID, database.RoleCustomer),
+			"chat admin": s.addUser(chatAdminID, database.RoleCustomer),
+			"member":     s.addUser(memberID, database.RoleCustomer),
+			"outsider":   s.addUser(outsiderID, database.RoleCustomer),
+			"site admin": s.addUser(siteAdminID, database.RoleAdmin),
+		}
+		s.addUser(newcomerID, database.RoleCustomer)
+
+		ctx := context.Background()
+		chat := database.Chat{ChatID: "chat-1", Owner: ownerID, Admins: []string{chatAdminID}, Users: []string{ownerID, chatAdminID, memberID}}
+		if err := s.stores.Chats.CreateChat(ctx, chat); err != nil {
+			t.Fatal(err)
+		}
+		messageID, err := database.GenerateMessageID(memberID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.stores.Chats.CreateMessage(ctx, database.Message{MessageID: messageID, Chat: "chat-1", Sender: memberID, Text: "hello"}); err != nil {
+			t.Fatal(err)
+		}
+		return s, tokens, messageID
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		caller   string
+		body     any
+		want     int
+		wantCode string
+	}{
+		{name: "outsider cannot read", method: http.MethodGet, path: "/chats/chat-1/messages", caller: "outsider", want: http.StatusForbidden, wantCode: "forbidden"},
+		{name: "member reads", method: http.MethodGet, path: "/chats/chat-1/messages", caller: "member", want: http.StatusOK},
+		{name: "site admin reads", method: http.MethodGet, path: "/chats/chat-1", caller: "site admin", want: http.StatusOK},
+		{name: "outsider cannot post", method: http.MethodPost, path: "/messages", caller: "outsider", body: map[string]string{"chat": "chat-1", "text": "hi"}, want: http.StatusForbidden, wantCode: "forbidden"},
+		{name: "member posts", method: http.MethodPost, path: "/messages", caller: "member", body: map[string]string{"chat": "chat-1", "text": "hi"}, want: http.StatusCreated},
+
+		{name: "member cannot add", method: http.MethodPost, path: "/chats/chat-1/members", caller: "member", body: map[string]string{"user_id": newcomerID}, want: http.StatusForbidden, wantCode: "forbidden"},
+		{name: "chat admin adds a member", method: http.MethodPost, path: "/chats/chat-1/members", caller: "chat admin", body: map[string]string{"user_id": newcomerID}, want: http.StatusOK},
+		{name: "chat admin cannot grant admin", method: http.MethodPost, path: "/chats/chat-1/members", caller: "chat admin", body: map[string]string{"user_id": newcomerID, "role": "admin"}, want: http.StatusForbidden, wantCode: "forbidden"},
+		{name: "owner grants admin", method: http.MethodPost, path: "/chats/chat-1/members", caller: "owner", body: map[string]string{"user_id": memberID, "role": "admin"}, want: http.StatusOK},
+		{name: "owner role cannot change", method: http.MethodPost, path: "/chats/chat-1/members", caller: "chat admin", body: map[string]string{"user_id": ownerID}, want: http.StatusConflict, wantCode: "chat_owner"},
+		{name: "unknown user", method: http.MethodPost, path: "/chats/chat-1/members", caller: "owner", body: map[string]string{"user_id": "nobody"}, want: http.StatusUnprocessableEntity, wantCode: "validation_failed"},
+
+		{name: "member leaves", method: http.MethodDelete, path: "/chats/chat-1/members/" + memberID, caller: "member", want: http.StatusOK},
+		{name: "member cannot remove others", method: http.MethodDelete, path: "/chats/chat-1/members/" + chatAdminID, caller: "member", want: http.StatusForbidden, wantCode: "forbidden"},
+		{name: "chat admin removes a member", method: http.MethodDelete, path: "/chats/chat-1/members/" + memberID, caller: "chat admin", want: http.StatusOK},
+		{name: "site admin removes a chat admin", method: http.MethodDelete, path: "/chats/chat-1/members/" + chatAdminID, caller: "site admin", want: http.StatusOK},
+		{name: "owner cannot leave", method: http.MethodDelete, path: "/chats/chat-1/members/" + ownerID, caller: "owner", want: http.StatusConflict, wantCode: "chat_owner"},
+		{name: "removing a non-member", method: http.MethodDelete, path: "/chats/chat-1/members/" + outsiderID, caller: "owner", want: http.StatusNotFound, wantCode: "not_found"},
+
+		{name: "chat admin cannot delete the chat", method: http.MethodDelete, path: "/chats/chat-1", caller: "chat admin", want: http.StatusForbidden, wantCode: "forbidden"},
+		{name: "owner deletes the chat", method: http.MethodDelete, path: "/chats/chat-1", caller: "owner", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, tokens, _ := setup(t)
+			w := s.do(tt.method, tt.path, tokens[tt.caller], tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.wantCode != "" {
+				if resp := decodeError(t, w); resp.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", resp.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+
+	t.Run("moderation", func(t *testing.T) {
+		s, tokens, messageID := setup(t)
+		if w := s.do(http.MethodPut, "/messages/"+messageID, tokens["chat admin"], map[string]string{"text": "edited"}); w.Code != http.StatusForbidden {
+			t.Errorf("chat admin editing another's message: status = %d, want 403", w.Code)
+		}
+		if w := s.do(http.MethodDelete, "/messages/"+messageID, tokens["outsider"], nil); w.Code != http.StatusForbidden {
+			t.Errorf("outsider deleting: status = %d, want 403", w.Code)
+		}
+		if w := s.do(http.MethodDelete, "/messages/"+messageID, tokens["chat admin"], nil); w.Code != http.StatusOK {
+			t.Errorf("chat admin deleting: status = %d, want 200; body %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("removed members lose access", func(t *testing.T) {
+		s, tokens, messageID := setup(t)
+		if w := s.do(http.MethodDelete, "/chats/chat-1/members/"+memberID, tokens["owner"], nil); w.Code != http.StatusOK {
+			t.Fatalf("removing: status = %d, body %s", w.Code, w.Body.String())
+		}
+		if w := s.do(http.MethodGet, "/chats/chat-1/messages", tokens["member"], nil); w.Code != http.StatusForbidden {
+			t.Errorf("reading after removal: status = %d, want 403", w.Code)
+		}
+		if w := s.do(http.MethodPut, "/messages/"+messageID, tokens["member"], map[string]string{"text": "edited"}); w.Code != http.StatusForbidden {
+			t.Errorf("editing own message after removal: status = %d, want 403", w.Code)
+		}
+	})
+}
@@ -0,0 +1,350 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/database"
+	"fuzzy-succotash-balance/main.go/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateChatRequest struct {
+	Users []string `json:"users"`
+}
+
+// CreateChat starts a chat owned by the caller with the listed users as
+// members.
+func CreateChat(s *database.Stores, c *gin.Context) {
+	var req CreateChatRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	claims := ClaimsFromContext(c)
+	chat := database.Chat{
+		ChatID: database.GenerateChatID(time.Now()),
+		Owner:  claims.ID,
+		Users:  []string{claims.ID},
+	}
+	for _, userID := range req.Users {
+		if !slices.Contains(chat.Users, userID) {
+			chat.Users = append(chat.Users, userID)
+		}
+	}
+
+	if err := s.Chats.CreateChat(c, chat); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Chat created successfully", "chat_id": chat.ChatID})
+}
+
+// publish announces a chat change to WebSocket subscribers on every replica.
+// Failing to notify does not fail the request; clients can still fetch.
+func publish(c *gin.Context, events database.EventBus, event database.ChatEvent) {
+	if err := events.Publish(c, event); err != nil {
+		Logger(c).Warn("could not publish chat event", "error", err, "event", event.Type, "chat_id", event.ChatID)
+	}
+}
+
+type CreateMessageRequest struct {
+	Chat  string   `json:"chat" binding:"required"`
+	Text  string   `json:"text"`
+	Media []string `json:"media"`
+}
+
+// CreateMessage posts to a chat the caller is a member of. The sender is
+// always the caller.
+func CreateMessage(s *database.Stores, events database.EventBus, c *gin.Context) {
+	var req CreateMessageRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	claims := ClaimsFromContext(c)
+	member, err := s.Chats.IsMember(c, req.Chat, claims.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if !member {
+		respondError(c, errForbidden)
+		return
+	}
+
+	msg := database.Message{Chat: req.Chat, Sender: claims.ID, Text: req.Text, Media: req.Media}
+	messageID, err := database.GenerateMessageID(msg.Sender)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	msg.MessageID = messageID
+
+	if err := s.Chats.CreateMessage(c, msg); err != nil {
+		respondError(c, err)
+		return
+	}
+	metrics.MessagesCreated.Inc()
+	publish(c, events, database.ChatEvent{Type: database.MessageCreated, ChatID: msg.Chat, MessageID: msg.MessageID})
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Message created successfully"})
+}
+
+// ChatView is a chat as listed to the caller, with how many messages from
+// other members they have not read yet.
+type ChatView struct {
+	database.Chat
+	UnreadCount int `json:"unread_count"`
+}
+
+// GetAllChats lists every chat for admins and only the caller's chats for
+// everyone else.
+func GetAllChats(s *database.Stores, c *gin.Context) {
+	page, err := pageRequest(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var filter database.ChatFilter
+	if claims := ClaimsFromContext(c); claims != nil && !claims.HasRole(database.RoleAdmin) {
+		filter.Member = claims.ID
+	}
+	chats, err := s.Chats.ListChats(c, filter, page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	ids := make([]string, len(chats.Items))
+	for i, chat := range chats.Items {
+		ids[i] = chat.ChatID
+	}
+	unread, err := s.Chats.UnreadCounts(c, ClaimsFromContext(c).ID, ids)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	views := make([]ChatView, len(chats.Items))
+	for i, chat := range chats.Items {
+		views[i] = ChatView{Chat: chat, UnreadCount: unread[chat.ChatID]}
+	}
+	c.JSON(http.StatusOK, database.Page[ChatView]{Items: views, NextCursor: chats.NextCursor})
+}
+
+// GetUserChats lists the chats :id belongs to with a preview of each one's
+// last message, most recently active first unless ?sort= says otherwise.
+func GetUserChats(s *database.Stores, c *gin.Context) {
+	page, err := pageRequest(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if c.Query("sort") == "" {
+		page.Desc = true
+	}
+
+	chats, err := s.Chats.ListChatSummaries(c, database.ChatFilter{Member: c.Param("id")}, page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chats)
+}
+
+// GetChatWithMessages returns a chat and one page of its history, newest
+// first. ?before= and ?after= take a message ID to page older or newer from.
+func GetChatWithMessages(s *database.Stores, c *gin.Context) {
+	chatID := c.Param("chatID")
+	req, err := messagePageRequest(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	chat, err := s.Chats.GetChat(c, chatID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	page, err := s.Chats.ListMessages(c, chatID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	receipts, err := s.Chats.ReadReceipts(c, chatID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	views := make([]MessageView, len(page.Messages))
+	for i, msg := range page.Messages {
+		views[i] = MessageView{Message: msg, ReadBy: readBy(msg, receipts)}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"chat":            chat,
+		"messages":        views,
+		"has_more_before": page.HasMoreBefore,
+		"has_more_after":  page.HasMoreAfter,
+	})
+}
+
+// MessageView is a message with the members, other than its sender, who have
+// read it.
+type MessageView struct {
+	database.Message
+	ReadBy []string `json:"read_by"`
+}
+
+func readBy(msg database.Message, receipts []database.ReadReceipt) []string {
+	users := []string{}
+	for _, receipt := range receipts {
+		if receipt.UserID != msg.Sender && receipt.HasRead(msg) {
+			users = append(users, receipt.UserID)
+		}
+	}
+	return users
+}
+
+type MarkReadRequest struct {
+	MessageID string `json:"message_id"`
+}
+
+// MarkChatRead moves the caller's read position in :chatID forward to
+// message_id, or to the latest message if the body is empty.
+func MarkChatRead(s *database.Stores, events database.EventBus, c *gin.Context) {
+	var req MarkReadRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	chatID := c.Param("chatID")
+	receipt, err := s.Chats.MarkRead(c, chatID, ClaimsFromContext(c).ID, req.MessageID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if receipt.LastReadMessageID != "" {
+		publish(c, events, database.ChatEvent{Type: database.MessagesRead, ChatID: chatID, UserID: receipt.UserID, MessageID: receipt.LastReadMessageID})
+	}
+
+	c.JSON(http.StatusOK, receipt)
+}
+
+func GetChatByID(s *database.Stores, c *gin.Context) {
+	chat, err := s.Chats.GetChat(c, c.Param("chatID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, chat)
+}
+
+func DeleteChatByID(s *database.Stores, events database.EventBus, c *gin.Context) {
+	chatID := c.Param("chatID")
+	if err := s.Chats.DeleteChat(c, chatID); err != nil {
+		respondError(c, err)
+		return
+	}
+	publish(c, events, database.ChatEvent{Type: database.ChatDeleted, ChatID: chatID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Chat deleted successfully"})
+}
+
+type UpdateMessageRequest struct {
+	Text  string   `json:"text"`
+	Media []string `json:"media"`
+}
+
+// UpdateMessageByID replaces the text and media of one of the caller's
+// messages. The previous version is kept in the message's edit history.
+func UpdateMessageByID(s *database.Stores, events database.EventBus, c *gin.Context) {
+	var req UpdateMessageRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Text == "" && len(req.Media) == 0 {
+		respondError(c, fieldInvalid("text", "required", "is required when there is no media"))
+		return
+	}
+
+	msg, err := s.Chats.EditMessage(c, c.Param("messageID"), req.Text, req.Media)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	publish(c, events, database.ChatEvent{Type: database.MessageUpdated, ChatID: msg.Chat, MessageID: msg.MessageID})
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// GetMessageEdits lists the earlier versions of a message, oldest first.
+func GetMessageEdits(s *database.Stores, c *gin.Context) {
+	edits, err := s.Chats.MessageEdits(c, c.Param("messageID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"edits": edits})
+}
+
+// DeleteMessageByID leaves a tombstone in place of the message so the
+// conversation keeps its shape.
+func DeleteMessageByID(s *database.Stores, events database.EventBus, c *gin.Context) {
+	msg, err := s.Chats.GetMessage(c, c.Param("messageID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := s.Chats.DeleteMessage(c, msg.MessageID); err != nil {
+		respondError(c, err)
+		return
+	}
+	publish(c, events, database.ChatEvent{Type: database.MessageDeleted, ChatID: msg.Chat, MessageID: msg.MessageID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message deleted successfully"})
+}
+
+// AddReaction reacts to a message as the caller with :emoji and responds with
+// the message's updated reaction counts.
+func AddReaction(s *database.Stores, events database.EventBus, c *gin.Context) {
+	messageID := c.Param("messageID")
+	if err := s.Chats.AddReaction(c, messageID, ClaimsFromContext(c).ID, c.Param("emoji")); err != nil {
+		respondError(c, err)
+		return
+	}
+	respondReacted(s, events, c, messageID)
+}
+
+// RemoveReaction takes back the caller's :emoji reaction.
+func RemoveReaction(s *database.Stores, events database.EventBus, c *gin.Context) {
+	messageID := c.Param("messageID")
+	if err := s.Chats.RemoveReaction(c, messageID, ClaimsFromContext(c).ID, c.Param("emoji")); err != nil {
+		respondError(c, err)
+		return
+	}
+	respondReacted(s, events, c, messageID)
+}
+
+func respondReacted(s *database.Stores, events database.EventBus, c *gin.Context, messageID string) {
+	msg, err := s.Chats.GetMessage(c, messageID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	publish(c, events, database.ChatEvent{Type: database.MessageUpdated, ChatID: msg.Chat, MessageID: msg.MessageID})
+
+	c.JSON(http.StatusOK, msg)
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrorResponse is the body of every error the API returns. Code is stable
+// and machine-readable; Message is for humans and may change.
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id"`
+}
+
+// FieldError is one entry of a validation error's details.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// apiError is an error raised by the HTTP layer itself rather than a store.
+type apiError struct {
+	status  int
+	code    string
+	message string
+	details any
+}
+
+func (e *apiError) Error() string { return e.message }
+
+func newAPIError(status int, code string, message string) *apiError {
+	return &apiError{status: status, code: code, message: message}
+}
+
+func validationFailed(fields []FieldError) *apiError {
+	resp := newAPIError(http.StatusUnprocessableEntity, "validation_failed", "Request body failed validation")
+	resp.details = fields
+	return resp
+}
+
+// fieldInvalid is a validation error for one field that binding tags cannot
+// express.
+func fieldInvalid(field string, rule string, message string) *apiError {
+	return validationFailed([]FieldError{{Field: field, Rule: rule, Message: message}})
+}
+
+var (
+	errUnauthenticated = newAPIError(http.StatusUnauthorized, "unauthenticated", "Authentication Header is missing!")
+	errInvalidToken    = newAPIError(http.StatusUnauthorized, "invalid_token", "Failed to verify token!")
+	errRateLimited     = newAPIError(http.StatusTooManyRequests, "rate_limited", "Too many requests")
+	errForbidden       = newAPIError(http.StatusForbidden, "forbidden", "You do not have permission to perform this action")
+	errRouteNotFound   = newAPIError(http.StatusNotFound, "route_not_found", "No such route")
+	errInternal        = newAPIError(http.StatusInternalServerError, "internal", "internal server error")
+)
+
+// errorKinds maps the database package's typed errors to a status and code.
+// The first match wins, so specific errors come before the generic
+// ErrNotFound, ErrConflict and ErrInvalid.
+var errorKinds = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{database.ErrRefreshTokenInvalid, http.StatusUnauthorized, "refresh_token_invalid"},
+	{database.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+	{database.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{database.ErrPasswordMismatch, http.StatusForbidden, "password_mismatch"},
+	{database.ErrEmailNotVerified, http.StatusForbidden, "email_not_verified"},
+	{database.ErrVerificationThrottled, http.StatusTooManyRequests, "verification_throttled"},
+	{database.ErrLoginLocked, http.StatusTooManyRequests, "login_locked"},
+	{database.ErrResetTokenInvalid, http.StatusBadRequest, "reset_token_invalid"},
+	{database.ErrVerificationTokenInvalid, http.StatusBadRequest, "verification_token_invalid"},
+	{errInvalidOrderNumber, http.StatusBadRequest, "invalid_order_number"},
+	{errBadQuery, http.StatusBadRequest, "invalid_query"},
+	{database.ErrNotFound, http.StatusNotFound, "not_found"},
+	{database.ErrConflict, http.StatusConflict, "conflict"},
+	{database.ErrInvalid, http.StatusUnprocessableEntity, "invalid"},
+}
+
+// classify turns err into the response sent for it. Anything unrecognised is
+// an internal error.
+func classify(err error) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind.err) {
+			resp := newAPIError(kind.status, kind.code, err.Error())
+			var param *queryParamError
+			if errors.As(err, &param) {
+				resp.details = map[string]string{"param": param.param}
+			}
+			return resp
+		}
+	}
+	return errInternal
+}
+
+// respondError writes err in the standard envelope. Internal errors are
+// logged and replaced by a generic message; the request ID lets support find
+// the log line.
+func respondError(c *gin.Context, err error) {
+	resp := classify(err)
+	if resp.status == http.StatusInternalServerError {
+		Logger(c).Error("internal error", "error", err)
+	}
+	writeError(c, resp)
+}
+
+func writeError(c *gin.Context, resp *apiError) {
+	c.JSON(resp.status, ErrorResponse{
+		Code:      resp.code,
+		Message:   resp.message,
+		Details:   resp.details,
+		RequestID: requestIDFrom(c),
+	})
+}
+
+// abortError is respondError for middleware.
+func abortError(c *gin.Context, err error) {
+	respondError(c, err)
+	c.Abort()
+}
+
+// bindJSON decodes the request body into obj and validates its binding tags,
+// responding with the problem and returning false if either fails.
+func bindJSON(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		respondError(c, bindError(err))
+		return false
+	}
+	return true
+}
+
+// bindError describes why a body could not be bound: 422 with one FieldError
+// per failed rule, or 400 if the body is not the right JSON at all.
+func bindError(err error) *apiError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, len(validationErrs))
+		for i, fe := range validationErrs {
+			fields[i] = FieldError{Field: fieldPath(fe), Rule: fe.Tag(), Message: fieldMessage(fe)}
+		}
+		return validationFailed(fields)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		resp := newAPIError(http.StatusBadRequest, "invalid_body", "Field "+typeErr.Field+" must be "+jsonKind(typeErr.Type))
+		resp.details = []FieldError{{Field: typeErr.Field, Rule: "type", Message: "must be " + jsonKind(typeErr.Type)}}
+		return resp
+	}
+	if errors.Is(err, io.EOF) {
+		return newAPIError(http.StatusBadRequest, "invalid_body", "Request body is required")
+	}
+	return newAPIError(http.StatusBadRequest, "invalid_body", "Request body is not valid JSON")
+}
+
+// fieldPath is the JSON path of the failed field without the top-level
+// struct name, e.g. "items[0].upc".
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return "must be at least " + fe.Param() + " characters"
+		}
+		if fe.Kind() == reflect.Slice {
+			return "must contain at least " + fe.Param() + " items"
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if fe.Kind() == reflect.String {
+			return "must be at most " + fe.Param() + " characters"
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	case "url":
+		return "must be a URL"
+	default:
+		return "failed the " + fe.Tag() + " rule"
+	}
+}
+
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// Validation errors name fields as the client sent them, by their JSON tag.
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "not found", err: fmt.Errorf("loading: %w", database.ErrNotFound), wantStatus: http.StatusNotFound, wantCode: "not_found"},
+		{name: "conflict", err: database.ErrConflict, wantStatus: http.StatusConflict, wantCode: "conflict"},
+		{name: "invalid", err: database.ErrInvalid, wantStatus: http.StatusUnprocessableEntity, wantCode: "invalid"},
+		{name: "specific before generic", err: &database.LockoutError{}, wantStatus: http.StatusTooManyRequests, wantCode: "login_locked"},
+		{name: "bad query", err: &queryParamError{"limit", "must be a positive integer"}, wantStatus: http.StatusBadRequest, wantCode: "invalid_query"},
+		{name: "api error", err: errForbidden, wantStatus: http.StatusForbidden, wantCode: "forbidden"},
+		{name: "unknown", err: errors.New("connection refused"), wantStatus: http.StatusInternalServerError, wantCode: "internal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := classify(tt.err)
+			if resp.status != tt.wantStatus || resp.code != tt.wantCode {
+				t.Errorf("classify = %d %s, want %d %s", resp.status, resp.code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	s := newTestServer(t)
+	token := s.addUser("customer-1", database.RoleCustomer)
+
+	raw := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name        string
+		w           *httptest.ResponseRecorder
+		wantStatus  int
+		wantCode    string
+		wantDetails string
+	}{
+		{
+			name:        "validation failed",
+			w:           s.do(http.MethodPost, "/register", "", map[string]string{"name": "Ada", "email": "not an email", "password": "short"}),
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantCode:    "validation_failed",
+			wantDetails: `[map[field:email message:must be a valid email address rule:email] map[field:password message:must be at least 8 characters rule:min]]`,
+		},
+		{
+			name:       "malformed json",
+			w:          raw(http.MethodPost, "/register", `{"name":`),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_body",
+		},
+		{
+			name:       "empty body",
+			w:          raw(http.MethodPost, "/register", ""),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_body",
+		},
+		{
+			name:        "wrong type",
+			w:           raw(http.MethodPost, "/register", `{"name": 7}`),
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_body",
+			wantDetails: `[map[field:name message:must be a string rule:type]]`,
+		},
+		{
+			name:        "bad query",
+			w:           s.do(http.MethodGet, "/products?limit=none", token, nil),
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    "invalid_query",
+			wantDetails: `map[param:limit]`,
+		},
+		{
+			name:       "unknown route",
+			w:          s.do(http.MethodGet, "/nowhere", token, nil),
+			wantStatus: http.StatusNotFound,
+			wantCode:   "route_not_found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", tt.w.Code, tt.wantStatus, tt.w.Body.String())
+			}
+			resp := decodeError(t, tt.w)
+			if resp.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", resp.Code, tt.wantCode)
+			}
+			if resp.Message == "" {
+				t.Error("message is empty")
+			}
+			if tt.wantDetails != "" && fmt.Sprint(resp.Details) != tt.wantDetails {
+				t.Errorf("details = %v, want %s", resp.Details, tt.wantDetails)
+			}
+			if resp.RequestID == "" || resp.RequestID != tt.w.Header().Get(requestIDHeader) {
+				t.Errorf("request_id = %q, want the %s header %q", resp.RequestID, requestIDHeader, tt.w.Header().Get(requestIDHeader))
+			}
+		})
+	}
+}
+
+func TestInternalErrorHidden(t *testing.T) {
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/fail", func(c *gin.Context) {
+		respondError(c, errors.New("pq: password authentication failed for user admin"))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+	req.Header.Set(requestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	resp := decodeError(t, w)
+	if w.Code != http.StatusInternalServerError || resp.Code != "internal" {
+		t.Fatalf("status = %d, code %q; want 500 internal", w.Code, resp.Code)
+	}
+	if strings.Contains(w.Body.String(), "pq:") {
+		t.Errorf("body %s leaks the underlying error", w.Body.String())
+	}
+	if resp.RequestID != "req-123" {
+		t.Errorf("request_id = %q, want the caller's req-123", resp.RequestID)
+	}
+}
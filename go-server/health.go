@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version and Commit are stamped at build time with
+//
+//	-ldflags "-X fuzzy-succotash-balance/main.go/go-server.Version=... -X fuzzy-succotash-balance/main.go/go-server.Commit=..."
+//
+// Commit falls back to the VCS revision Go embeds when building from a
+// checkout.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+var startedAt = time.Now()
+
+const readinessTimeout = 2 * time.Second
+
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// Livez reports that the process is up. It deliberately checks nothing else so
+// a database outage does not get every pod restarted.
+func Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// checkReady pings the database and checks the schema is fully migrated.
+func checkReady(ctx context.Context, db *sql.DB) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	checks := map[string]string{"database": "ok", "migrations": "ok"}
+	if err := db.PingContext(ctx); err != nil {
+		checks["database"] = err.Error()
+		checks["migrations"] = "unknown"
+		return checks
+	}
+	pending, err := database.PendingMigrations(ctx, db)
+	if err != nil {
+		checks["migrations"] = err.Error()
+	} else if pending > 0 {
+		checks["migrations"] = fmt.Sprintf("%d pending", pending)
+	}
+	return checks
+}
+
+func ready(checks map[string]string) bool {
+	for _, result := range checks {
+		if result != "ok" {
+			return false
+		}
+	}
+	return true
+}
+
+// Readyz reports whether this pod should receive traffic. It is public, so
+// failed checks are logged rather than returned; admins can see them at
+// /health/details.
+func Readyz(db *sql.DB, c *gin.Context) {
+	checks := checkReady(c, db)
+	if !ready(checks) {
+		Logger(c).Warn("not ready", "checks", checks)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// HealthDetails is Readyz plus pool statistics and build information, for
+// admins.
+func HealthDetails(db *sql.DB, c *gin.Context) {
+	checks := checkReady(c, db)
+	status := "ok"
+	if !ready(checks) {
+		status = "unavailable"
+	}
+
+	stats := db.Stats()
+	c.JSON(http.StatusOK, gin.H{
+		"status":  status,
+		"checks":  checks,
+		"version": Version,
+		"commit":  buildCommit(),
+		"uptime":  time.Since(startedAt).Round(time.Second).String(),
+		"database_pool": gin.H{
+			"max_open_connections": stats.MaxOpenConnections,
+			"open_connections":     stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration":        stats.WaitDuration.String(),
+			"max_idle_closed":      stats.MaxIdleClosed,
+			"max_lifetime_closed":  stats.MaxLifetimeClosed,
+		},
+	})
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = (wsPongWait * 9) / 10
+	wsSendBuffer = 32
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Clients authenticate with a bearer token rather than cookies, so
+	// cross-origin upgrades carry no ambient credentials.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type wsClient struct {
+	userID string
+	chatID string
+	conn   *websocket.Conn
+	send   chan []byte
+}
+
+// chatRoom is the set of sockets connected to one chat.
+type chatRoom struct {
+	clients map[*wsClient]struct{}
+}
+
+// Hub pushes chat events to the WebSocket clients connected to this
+// replica. Events arrive from the EventBus, so messages created on other
+// replicas are delivered too.
+type Hub struct {
+	mu    sync.RWMutex
+	rooms map[string]*chatRoom
+	chats database.ChatStore
+}
+
+func NewHub(chats database.ChatStore, events database.EventBus) *Hub {
+	h := &Hub{rooms: make(map[string]*chatRoom), chats: chats}
+	events.Subscribe(h.dispatch)
+	return h
+}
+
+func (h *Hub) register(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, ok := h.rooms[client.chatID]
+	if !ok {
+		room = &chatRoom{clients: make(map[*wsClient]struct{})}
+		h.rooms[client.chatID] = room
+	}
+	room.clients[client] = struct{}{}
+}
+
+func (h *Hub) unregister(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, ok := h.rooms[client.chatID]
+	if !ok {
+		return
+	}
+	if _, ok := room.clients[client]; ok {
+		delete(room.clients, client)
+		close(client.send)
+	}
+	if len(room.clients) == 0 {
+		delete(h.rooms, client.chatID)
+	}
+}
+
+// Close disconnects every client. Each writePump sends a close frame as its
+// send channel is closed.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for chatID, room := range h.rooms {
+		for client := range room.clients {
+			close(client.send)
+		}
+		delete(h.rooms, chatID)
+	}
+}
+
+func (h *Hub) hasRoom(chatID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.rooms[chatID]
+	return ok
+}
+
+func (h *Hub) dispatch(event database.ChatEvent) {
+	if !h.hasRoom(event.ChatID) {
+		return
+	}
+
+	if (event.Type == database.MessageCreated || event.Type == database.MessageUpdated) && event.Message == nil {
+		msg, err := h.chats.GetMessage(context.Background(), event.MessageID)
+		if err != nil {
+			slog.Error("hub: could not load message", "error", err, "message_id", event.MessageID)
+			return
+		}
+		event.Message = &msg
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("hub: could not encode event", "error", err)
+		return
+	}
+	h.broadcast(event.ChatID, payload)
+
+	// Sockets only last as long as the membership that allowed them.
+	switch event.Type {
+	case database.MemberRemoved:
+		h.disconnect(event.ChatID, event.UserID)
+	case database.ChatDeleted:
+		h.disconnect(event.ChatID, "")
+	}
+}
+
+// disconnect closes userID's sockets in chatID, or every socket in it if
+// userID is empty.
+func (h *Hub) disconnect(chatID string, userID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room, ok := h.rooms[chatID]
+	if !ok {
+		return
+	}
+	for client := range room.clients {
+		if userID == "" || client.userID == userID {
+			delete(room.clients, client)
+			close(client.send)
+		}
+	}
+	if len(room.clients) == 0 {
+		delete(h.rooms, chatID)
+	}
+}
+
+func (h *Hub) broadcast(chatID string, payload []byte) {
+	h.mu.RLock()
+	room, ok := h.rooms[chatID]
+	var slow []*wsClient
+	if ok {
+		for client := range room.clients {
+			select {
+			case client.send <- payload:
+			default:
+				slow = append(slow, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	// Clients that cannot keep up are dropped rather than blocking the room.
+	for _, client := range slow {
+		h.unregister(client)
+	}
+}
+
+// ServeChat upgrades the request to a WebSocket subscribed to :chatID. It must
+// run after VerifyJWT and RequireChatMember.
+func (h *Hub) ServeChat(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response.
+		return
+	}
+
+	client := &wsClient{
+		userID: ClaimsFromContext(c).ID,
+		chatID: c.Param("chatID"),
+		conn:   conn,
+		send:   make(chan []byte, wsSendBuffer),
+	}
+	h.register(client)
+
+	go client.writePump()
+	go client.readPump(h)
+}
+
+// readPump discards anything the client sends and notices when it goes away.
+func (c *wsClient) readPump(h *Hub) {
+	defer func() {
+		h.unregister(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(512)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *wsClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid/v5"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// gin's debug output is plain text on stdout; send it through slog so every
+// line stays JSON.
+func init() {
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(strings.TrimPrefix(format, "[WARNING] "), values...)))
+	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		slog.Debug("route", "method", method, "path", path, "handler", handler)
+	}
+}
+
+// Incoming request IDs are only trusted if they look like an ID, so callers
+// cannot inject arbitrary text into the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID propagates the caller's X-Request-ID, or assigns one, and echoes
+// it on the response. It must run before anything that logs.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.Must(uuid.NewV4()).String()
+		}
+		c.Set("requestID", id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+func requestIDFrom(c *gin.Context) string {
+	return c.GetString("requestID")
+}
+
+// Logger returns the default logger annotated with the request ID, route
+// template and, once VerifyJWT has run, the caller's user ID.
+func Logger(c *gin.Context) *slog.Logger {
+	attrs := []any{
+		"request_id", requestIDFrom(c),
+		"method", c.Request.Method,
+		"route", c.FullPath(),
+	}
+	if claims := ClaimsFromContext(c); claims != nil {
+		attrs = append(attrs, "user_id", claims.ID)
+	}
+	return slog.Default().With(attrs...)
+}
+
+// AccessLog writes one line per request, at warn for 4xx and error for 5xx.
+// It replaces gin's text logger.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		Logger(c).Log(c, level, "request",
+			"path", c.Request.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// Recovery turns a panic into a logged, sanitised 500.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		Logger(c).Error("panic", "panic", recovered, "stack", string(debug.Stack()))
+		writeError(c, errInternal)
+		c.Abort()
+	})
+}
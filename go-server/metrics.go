@@ -0,0 +1,38 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics records every request's count and latency. Routes are labelled by
+// their template, e.g. /orders/:orderNumber, so IDs do not explode the
+// series; requests that match no route share the "unmatched" label.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveQuery is set as database.QueryObserver.
+func ObserveQuery(store string, method string, elapsed time.Duration) {
+	metrics.QueryDuration.WithLabelValues(store, method).Observe(elapsed.Seconds())
+}
+
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+}
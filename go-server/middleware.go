@@ -0,0 +1,103 @@
+package server
+
+import (
+	"strings"
+
+	"fuzzy-succotash-balance/main.go/config"
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/websocket"
+)
+
+// publicPaths are served without an access token. They are matched
+// exactly so that e.g. /health/details still requires one.
+var publicPaths = map[string]bool{
+	"/register":        true,
+	"/login":           true,
+	"/auth/refresh":    true,
+	"/password/forgot": true,
+	"/password/reset":  true,
+	"/verify":          true,
+	"/verify/resend":   true,
+	"/health":          true,
+	"/livez":           true,
+	"/readyz":          true,
+}
+
+func isPublicPath(path string) bool {
+	return publicPaths[path]
+}
+
+func VerifyJWT(auth config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isPublicPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		authHeader := c.GetHeader("Authorization")
+		// Browser WebSocket clients cannot set headers, so upgrades may pass
+		// the access token as ?token= instead.
+		if authHeader == "" && websocket.IsWebSocketUpgrade(c.Request) {
+			authHeader = c.Query("token")
+		}
+		if authHeader == "" {
+			abortError(c, errUnauthenticated)
+			return
+		}
+
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		userClaims := database.ParseAccessToken(auth, token)
+		if userClaims == nil {
+			abortError(c, errInvalidToken)
+			return
+		}
+
+		c.Set("userClaims", userClaims)
+
+		c.Next()
+	}
+}
+
+// ClaimsFromContext returns the claims stored by VerifyJWT, or nil on routes
+// that skip authentication.
+func ClaimsFromContext(c *gin.Context) *database.UserClaims {
+	claims, ok := c.Get("userClaims")
+	if !ok {
+		return nil
+	}
+	userClaims, _ := claims.(*database.UserClaims)
+	return userClaims
+}
+
+type VerifyRefreshRequest struct {
+	Token string `json:"refreshToken" binding:"required"`
+}
+
+func VerifyRefreshToken(auth config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req VerifyRefreshRequest
+
+		if !bindJSON(c, &req) {
+			c.Abort()
+			return
+		}
+
+		claims := database.ParseRefreshToken(auth, req.Token)
+		if claims == nil {
+			abortError(c, errInvalidToken)
+			return
+		}
+
+		c.Set("refreshClaims", claims)
+
+		c.Next()
+	}
+}
+
+func refreshClaimsFromContext(c *gin.Context) *jwt.StandardClaims {
+	claims, _ := c.MustGet("refreshClaims").(*jwt.StandardClaims)
+	return claims
+}
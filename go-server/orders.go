@@ -0,0 +1,213 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fuzzy-succotash-balance/main.go/database"
+	"fuzzy-succotash-balance/main.go/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidOrderNumber = errors.New("Invalid order number")
+
+func orderNumberParam(c *gin.Context) (int, error) {
+	orderNumber, err := strconv.Atoi(c.Param("orderNumber"))
+	if err != nil {
+		return 0, errInvalidOrderNumber
+	}
+	return orderNumber, nil
+}
+
+type OrderItemRequest struct {
+	UPC      string `json:"upc" binding:"required"`
+	Quantity int    `json:"quantity" binding:"gt=0"`
+}
+
+// CreateOrderRequest carries only what the client chooses; prices and the
+// total are computed server-side.
+type CreateOrderRequest struct {
+	User  string             `json:"user"`
+	Items []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+func CreateOrder(s *database.Stores, c *gin.Context) {
+	var req CreateOrderRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	order := database.Order{Status: database.NotSent, User: req.User}
+	for _, item := range req.Items {
+		order.Items = append(order.Items, database.OrderItem{UPC: item.UPC, Quantity: item.Quantity})
+	}
+
+	// Staff may place orders for others; everyone else orders for
+	// themselves.
+	claims := ClaimsFromContext(c)
+	if order.User == "" || !claims.HasRole(database.RoleStaff, database.RoleAdmin) {
+		order.User = claims.ID
+	} else if _, err := s.Users.GetByID(c, order.User); errors.Is(err, database.ErrNotFound) {
+		respondError(c, fieldInvalid("user", "exists", "does not match a user"))
+		return
+	} else if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	order, err := s.Orders.Create(c, order)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	metrics.OrdersCreated.WithLabelValues(string(order.Status)).Inc()
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Order created!", "orderNumber": order.OrderNumber, "order": order})
+}
+
+// GetOrders accepts ?status=, ?user=, ?created_after= and ?created_before=
+// filters plus the usual paging parameters.
+func GetOrders(s *database.Stores, c *gin.Context) {
+	listOrders(s, c, "")
+}
+
+// GetOrdersByUser is GetOrders restricted to the user in :id.
+func GetOrdersByUser(s *database.Stores, c *gin.Context) {
+	listOrders(s, c, c.Param("id"))
+}
+
+func listOrders(s *database.Stores, c *gin.Context, userID string) {
+	filter, err := orderFilter(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if userID != "" {
+		filter.User = userID
+	}
+	page, err := pageRequest(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	orders, err := s.Orders.List(c, filter, page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func GetOrderByNumber(s *database.Stores, c *gin.Context) {
+	orderNumber, err := orderNumberParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	order, err := s.Orders.Get(c, orderNumber)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+type ReassignOrderRequest struct {
+	User string `json:"user" binding:"required"`
+}
+
+// UpdateOrderByNumber reassigns an order to another user.
+func UpdateOrderByNumber(s *database.Stores, c *gin.Context) {
+	orderNumber, err := orderNumberParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var req ReassignOrderRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	order := database.Order{OrderNumber: orderNumber, User: req.User}
+	if err = s.Orders.Update(c, order); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order updated!"})
+}
+
+type UpdateOrderStatusRequest struct {
+	Status database.OrderStatus `json:"status"`
+}
+
+// UpdateOrderStatus lets staff move an order through its lifecycle. Owners
+// may only cancel their own orders.
+func UpdateOrderStatus(s *database.Stores, c *gin.Context) {
+	orderNumber, err := orderNumberParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var req UpdateOrderStatusRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !req.Status.Valid() {
+		respondError(c, fieldInvalid("status", "valid", "is not a known order status"))
+		return
+	}
+
+	claims := ClaimsFromContext(c)
+	if !claims.HasRole(database.RoleStaff, database.RoleAdmin) && req.Status != database.Cancelled {
+		forbid(c)
+		return
+	}
+
+	order, err := s.Orders.UpdateStatus(c, orderNumber, req.Status, claims.ID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order status updated!", "order": order})
+}
+
+func GetOrderHistory(s *database.Stores, c *gin.Context) {
+	orderNumber, err := orderNumberParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	history, err := s.Orders.History(c, orderNumber)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func DeleteOrderByNumber(s *database.Stores, c *gin.Context) {
+	orderNumber, err := orderNumberParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := s.Orders.Delete(c, orderNumber); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order deleted!"})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"fuzzy-succotash-balance/main.go/database"
+)
+
+func TestCreateOrder(t *testing.T) {
+	s := newTestServer(t)
+	tokens := map[string]string{
+		"customer": s.addUser("customer-1", database.RoleCustomer),
+		"staff":    s.addUser("staff-1", database.RoleStaff),
+	}
+	s.addUser("customer-2", database.RoleCustomer)
+	if err := s.stores.Products.Create(context.Background(), database.Product{UPC: "upc-1", Name: "Widget", Price: database.NewMoney(250, "USD")}); err != nil {
+		t.Fatal(err)
+	}
+	items := []map[string]any{{"upc": "upc-1", "quantity": 2}, {"upc": "upc-1", "quantity": 1}}
+
+	tests := []struct {
+		name      string
+		caller    string
+		user      string
+		items     any
+		want      int
+		wantUser  string
+		wantField string
+	}{
+		{name: "customer orders for themselves", caller: "customer", items: items, want: http.StatusCreated, wantUser: "customer-1"},
+		{name: "customer cannot order for others", caller: "customer", user: "customer-2", items: items, want: http.StatusCreated, wantUser: "customer-1"},
+		{name: "staff orders for a customer", caller: "staff", user: "customer-2", items: items, want: http.StatusCreated, wantUser: "customer-2"},
+		{name: "staff without a user orders for themselves", caller: "staff", items: items, want: http.StatusCreated, wantUser: "staff-1"},
+		{name: "staff with an unknown user", caller: "staff", user: "nobody", items: items, want: http.StatusUnprocessableEntity, wantField: "user"},
+		{name: "no items", caller: "customer", items: []map[string]any{}, want: http.StatusUnprocessableEntity, wantField: "items"},
+		{name: "zero quantity", caller: "customer", items: []map[string]any{{"upc": "upc-1", "quantity": 0}}, want: http.StatusUnprocessableEntity, wantField: "items[0].quantity"},
+		{name: "unknown product", caller: "customer", items: []map[string]any{{"upc": "missing", "quantity": 1}}, want: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/orders", tokens[tt.caller], map[string]any{"user": tt.user, "items": tt.items})
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want != http.StatusCreated {
+				resp := decodeError(t, w)
+				if fields, _ := resp.Details.([]any); tt.wantField != "" && (len(fields) == 0 || fields[0].(map[string]any)["field"] != tt.wantField) {
+					t.Errorf("details = %v, want a %s field error", resp.Details, tt.wantField)
+				}
+				return
+			}
+
+			var body struct {
+				Order database.Order `json:"order"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Order.User != tt.wantUser {
+				t.Errorf("order user = %q, want %q", body.Order.User, tt.wantUser)
+			}
+			if len(body.Order.Items) != 1 || body.Order.Items[0].Quantity != 3 {
+				t.Errorf("items = %+v, want one line of 3", body.Order.Items)
+			}
+			if want := database.NewMoney(750, "USD"); body.Order.Total != want {
+				t.Errorf("total = %v, want %v", body.Order.Total, want)
+			}
+		})
+	}
+}
+
+func TestDeleteOrder(t *testing.T) {
+	s := newTestServer(t)
+	customer := s.addUser("customer-1", database.RoleCustomer)
+	staff := s.addUser("staff-1", database.RoleStaff)
+	ctx := context.Background()
+	if err := s.stores.Products.Create(ctx, database.Product{UPC: "upc-1", Name: "Widget", Price: database.NewMoney(250, "USD")}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := s.stores.Orders.Create(ctx, database.Order{Status: database.NotSent, User: "customer-1", Items: []database.OrderItem{{UPC: "upc-1", Quantity: 1}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w := s.do(http.MethodPatch, "/orders/2/status", staff, map[string]string{"status": "Sent"}); w.Code != http.StatusOK {
+		t.Fatalf("sending order 2: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	if w := s.do(http.MethodDelete, "/orders/2", customer, nil); w.Code != http.StatusConflict {
+		t.Errorf("deleting a sent order: status = %d, want 409; body %s", w.Code, w.Body.String())
+	}
+	var history []database.OrderStatusChange
+	w := s.do(http.MethodGet, "/orders/2/history", customer, nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil || len(history) != 1 {
+		t.Errorf("history of the sent order = %s, want one change", w.Body.String())
+	}
+	if w := s.do(http.MethodDelete, "/orders/1", customer, nil); w.Code != http.StatusOK {
+		t.Errorf("deleting an unsent order: status = %d, want 200; body %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errBadQuery = errors.New("invalid query parameter")
+
+// queryParamError reports a malformed query string parameter.
+type queryParamError struct {
+	param  string
+	reason string
+}
+
+func (e *queryParamError) Error() string {
+	return fmt.Sprintf("Invalid %s: %s", e.param, e.reason)
+}
+
+func (e *queryParamError) Unwrap() error { return errBadQuery }
+
+// pageRequest reads ?limit=, ?cursor= and ?sort= from the query string. A
+// leading "-" on sort orders descending, e.g. ?sort=-created_at.
+func pageRequest(c *gin.Context) (database.PageRequest, error) {
+	var page database.PageRequest
+
+	var err error
+	if page.Limit, err = queryLimit(c); err != nil {
+		return page, err
+	}
+
+	page.Cursor = c.Query("cursor")
+	page.Sort = c.Query("sort")
+	if strings.HasPrefix(page.Sort, "-") {
+		page.Sort = page.Sort[1:]
+		page.Desc = true
+	}
+	return page, nil
+}
+
+// messagePageRequest reads ?before=, ?after= and ?limit= for paging through a
+// chat's history.
+func messagePageRequest(c *gin.Context) (database.MessagePageRequest, error) {
+	page := database.MessagePageRequest{Before: c.Query("before"), After: c.Query("after")}
+	if page.Before != "" && page.After != "" {
+		return page, &queryParamError{"after", "cannot be combined with before"}
+	}
+
+	var err error
+	page.Limit, err = queryLimit(c)
+	return page, err
+}
+
+func queryLimit(c *gin.Context) (int, error) {
+	limit := c.Query("limit")
+	if limit == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		return 0, &queryParamError{"limit", "must be a positive integer"}
+	}
+	return n, nil
+}
+
+func queryTime(c *gin.Context, param string) (*time.Time, error) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, &queryParamError{param, "must be an RFC 3339 timestamp"}
+	}
+	return &t, nil
+}
+
+func queryMoney(c *gin.Context, param string) (*database.Money, error) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, nil
+	}
+	money, err := database.ParseMoney(value, c.Query("currency"))
+	if err != nil {
+		return nil, &queryParamError{param, err.Error()}
+	}
+	return &money, nil
+}
+
+func userFilter(c *gin.Context) database.UserFilter {
+	return database.UserFilter{NamePrefix: c.Query("name_prefix")}
+}
+
+func productFilter(c *gin.Context) (database.ProductFilter, error) {
+	filter := database.ProductFilter{NamePrefix: c.Query("name_prefix")}
+
+	var err error
+	if filter.MinPrice, err = queryMoney(c, "min_price"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrice, err = queryMoney(c, "max_price"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+func orderFilter(c *gin.Context) (database.OrderFilter, error) {
+	filter := database.OrderFilter{
+		Status: database.OrderStatus(c.Query("status")),
+		User:   c.Query("user"),
+	}
+
+	var err error
+	if filter.CreatedAfter, err = queryTime(c, "created_after"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = queryTime(c, "created_before"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"fuzzy-succotash-balance/main.go/database"
+	"fuzzy-succotash-balance/main.go/mailer"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword must run after RequireSelfOrRole("id"). Every session the
+// user has open is signed out.
+func ChangePassword(s *database.Stores, c *gin.Context) {
+	var req ChangePasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := database.ChangePassword(c, s.Users, s.Tokens, c.Param("id"), req.CurrentPassword, req.NewPassword); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated!"})
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+const forgotPasswordResponse = "If that account exists, a reset email has been sent"
+
+// ForgotPassword emails a reset token. It answers the same way whether or not
+// the email is registered so it cannot be used to discover accounts.
+func ForgotPassword(s *database.Stores, mail mailer.Mailer, c *gin.Context) {
+	var req ForgotPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := s.Users.GetByEmail(c, req.Email)
+	if errors.Is(err, database.ErrNotFound) {
+		c.JSON(http.StatusAccepted, gin.H{"message": forgotPasswordResponse})
+		return
+	} else if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	token, err := database.RequestPasswordReset(c, s.Tokens, user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	err = mail.Send(c, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Use this token to reset your password:\n\n%s\n\nIt expires in %s and can only be used once.\n",
+			token, database.PasswordResetTTL),
+	})
+	if err != nil {
+		Logger(c).Error("password reset email failed", "error", err, "target_user_id", user.ID)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": forgotPasswordResponse})
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func ResetPassword(s *database.Stores, c *gin.Context) {
+	var req ResetPasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := database.ResetPassword(c, s.Users, s.Tokens, req.Token, req.Password); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset!"})
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy middleware runs after VerifyJWT and aborts with 403 when the caller
+// is not allowed to use the route.
+
+func forbid(c *gin.Context) {
+	abortError(c, errForbidden)
+}
+
+func RequireRole(roles ...database.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := ClaimsFromContext(c)
+		if claims == nil || !claims.HasRole(roles...) {
+			forbid(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelfOrRole allows the user whose ID is in the given path parameter,
+// or anyone holding one of roles.
+func RequireSelfOrRole(param string, roles ...database.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := ClaimsFromContext(c)
+		if claims == nil || (claims.ID != c.Param(param) && !claims.HasRole(roles...)) {
+			forbid(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireOwnership is the shared shape of the resource policies below: roles
+// bypass the check, everyone else must satisfy owns.
+func requireOwnership(roles []database.Role, owns func(c *gin.Context, claims *database.UserClaims) (bool, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := ClaimsFromContext(c)
+		if claims == nil {
+			forbid(c)
+			return
+		}
+		if claims.HasRole(roles...) {
+			c.Next()
+			return
+		}
+
+		ok, err := owns(c, claims)
+		if err != nil {
+			abortError(c, err)
+			return
+		}
+		if !ok {
+			forbid(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func RequireOrderOwner(s *database.Stores, roles ...database.Role) gin.HandlerFunc {
+	return requireOwnership(roles, func(c *gin.Context, claims *database.UserClaims) (bool, error) {
+		orderNumber, err := orderNumberParam(c)
+		if err != nil {
+			return false, err
+		}
+		order, err := s.Orders.Get(c, orderNumber)
+		return order.User == claims.ID, err
+	})
+}
+
+func RequireChatMember(s *database.Stores, roles ...database.Role) gin.HandlerFunc {
+	return requireOwnership(roles, func(c *gin.Context, claims *database.UserClaims) (bool, error) {
+		return s.Chats.IsMember(c, c.Param("chatID"), claims.ID)
+	})
+}
+
+// RequireChatOwner allows only the user who created :chatID.
+func RequireChatOwner(s *database.Stores, roles ...database.Role) gin.HandlerFunc {
+	return requireOwnership(roles, func(c *gin.Context, claims *database.UserClaims) (bool, error) {
+		chat, err := s.Chats.GetChat(c, c.Param("chatID"))
+		return chat.Owner == claims.ID, err
+	})
+}
+
+// RequireMessageSender allows the sender of :messageID while they are still a
+// member of its chat.
+func RequireMessageSender(s *database.Stores, roles ...database.Role) gin.HandlerFunc {
+	return requireOwnership(roles, func(c *gin.Context, claims *database.UserClaims) (bool, error) {
+		msg, err := s.Chats.GetMessage(c, c.Param("messageID"))
+		if err != nil || msg.Sender != claims.ID {
+			return false, err
+		}
+		return s.Chats.IsMember(c, msg.Chat, claims.ID)
+	})
+}
+
+// RequireMessageMember allows members of the chat :messageID was posted in.
+func RequireMessageMember(s *database.Stores, roles ...database.Role) gin.HandlerFunc {
+	return requireOwnership(roles, func(c *gin.Context, claims *database.UserClaims) (bool, error) {
+		msg, err := s.Chats.GetMessage(c, c.Param("messageID"))
+		if err != nil {
+			return false, err
+		}
+		return s.Chats.IsMember(c, msg.Chat, claims.ID)
+	})
+}
+
+// RequireMessageModerator is RequireMessageSender that also allows the owner
+// and admins of the message's chat.
+func RequireMessageModerator(s *database.Stores, roles ...database.Role) gin.HandlerFunc {
+	return requireOwnership(roles, func(c *gin.Context, claims *database.UserClaims) (bool, error) {
+		msg, err := s.Chats.GetMessage(c, c.Param("messageID"))
+		if err != nil {
+			return false, err
+		}
+		chat, err := s.Chats.GetChat(c, msg.Chat)
+		if err != nil {
+			return false, err
+		}
+		role, ok := chat.RoleOf(claims.ID)
+		return ok && (msg.Sender == claims.ID || role.CanManage()), nil
+	})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"fuzzy-succotash-balance/main.go/database"
+)
+
+func TestRolePolicies(t *testing.T) {
+	s := newTestServer(t)
+	tokens := map[string]string{
+		"customer": s.addUser("customer-1", database.RoleCustomer),
+		"other":    s.addUser("customer-2", database.RoleCustomer),
+		"staff":    s.addUser("staff-1", database.RoleStaff),
+		"admin":    s.addUser("admin-1", database.RoleAdmin),
+	}
+	ctx := context.Background()
+	if err := s.stores.Products.Create(ctx, database.Product{UPC: "upc-1", Name: "Widget", Price: database.NewMoney(999, "USD")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.stores.Orders.Create(ctx, database.Order{Status: database.NotSent, User: "customer-1", Items: []database.OrderItem{{UPC: "upc-1", Quantity: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		caller string
+		body   any
+		want   int
+	}{
+		{method: http.MethodGet, path: "/orders", caller: "", want: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/orders", caller: "customer", want: http.StatusForbidden},
+		{method: http.MethodGet, path: "/orders", caller: "staff", want: http.StatusOK},
+		{method: http.MethodGet, path: "/orders", caller: "admin", want: http.StatusOK},
+
+		{method: http.MethodGet, path: "/orders/1", caller: "customer", want: http.StatusOK},
+		{method: http.MethodGet, path: "/orders/1", caller: "other", want: http.StatusForbidden},
+		{method: http.MethodGet, path: "/orders/1", caller: "staff", want: http.StatusOK},
+		{method: http.MethodGet, path: "/orders/99", caller: "customer", want: http.StatusNotFound},
+		{method: http.MethodPut, path: "/orders/1", caller: "customer", body: map[string]string{"user": "customer-2"}, want: http.StatusForbidden},
+		{method: http.MethodPatch, path: "/orders/1/status", caller: "customer", body: map[string]string{"status": "Sent"}, want: http.StatusForbidden},
+		{method: http.MethodPatch, path: "/orders/1/status", caller: "staff", body: map[string]string{"status": "Sent"}, want: http.StatusOK},
+
+		{method: http.MethodPost, path: "/products", caller: "customer", body: map[string]any{"upc": "upc-2", "name": "Gadget", "price": map[string]string{"amount": "1.00"}}, want: http.StatusForbidden},
+		{method: http.MethodPost, path: "/products", caller: "staff", body: map[string]any{"upc": "upc-2", "name": "Gadget", "price": map[string]string{"amount": "1.00"}}, want: http.StatusForbidden},
+		{method: http.MethodPost, path: "/products", caller: "admin", body: map[string]any{"upc": "upc-2", "name": "Gadget", "price": map[string]string{"amount": "1.00"}}, want: http.StatusCreated},
+		{method: http.MethodDelete, path: "/products/upc-2", caller: "customer", want: http.StatusForbidden},
+
+		{method: http.MethodPut, path: "/users/customer-1", caller: "customer", body: map[string]string{"name": "Ada"}, want: http.StatusOK},
+		{method: http.MethodPut, path: "/users/customer-1", caller: "other", body: map[string]string{"name": "Eve"}, want: http.StatusForbidden},
+		{method: http.MethodPut, path: "/users/customer-1/role", caller: "customer", body: map[string]string{"role": "admin"}, want: http.StatusForbidden},
+		{method: http.MethodPut, path: "/users/customer-2/role", caller: "admin", body: map[string]string{"role": "staff"}, want: http.StatusOK},
+		{method: http.MethodGet, path: "/users/customer-1/orders", caller: "other", want: http.StatusForbidden},
+		{method: http.MethodGet, path: "/users/customer-1/orders", caller: "customer", want: http.StatusOK},
+		{method: http.MethodGet, path: "/admin/login-failures", caller: "staff", want: http.StatusForbidden},
+		{method: http.MethodGet, path: "/admin/login-failures", caller: "admin", want: http.StatusOK},
+
+		{method: http.MethodGet, path: "/metrics", caller: "", want: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/metrics", caller: "staff", want: http.StatusForbidden},
+		{method: http.MethodGet, path: "/metrics", caller: "admin", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path+" as "+tt.caller, func(t *testing.T) {
+			w := s.do(tt.method, tt.path, tokens[tt.caller], tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d; body %s", w.Code, tt.want, w.Body.String())
+			}
+			if w.Code == http.StatusForbidden {
+				if resp := decodeError(t, w); resp.Code != "forbidden" {
+					t.Errorf("code = %q, want forbidden", resp.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidAccessToken(t *testing.T) {
+	s := newTestServer(t)
+	s.addUser("customer-1", database.RoleCustomer)
+
+	// Signed with another secret, so the admin role must not be trusted.
+	wrongKey := testAuth
+	wrongKey.TokenSecret = "another secret"
+	forged, err := database.NewAccessToken(wrongKey, database.UserClaims{ID: "customer-1", Role: database.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := s.do(http.MethodGet, "/orders", forged, nil)
+	if w.Code != http.StatusUnauthorized || decodeError(t, w).Code != "invalid_token" {
+		t.Errorf("forged token: status = %d, body %s; want 401 invalid_token", w.Code, w.Body.String())
+	}
+}
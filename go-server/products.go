@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validatePrice rejects a missing or negative price. An omitted price
+// decodes with no currency, which would bypass the column's default.
+func validatePrice(price database.Money) error {
+	if price.Currency == "" {
+		return fieldInvalid("price", "required", "is required")
+	}
+	if price.Amount < 0 {
+		return fieldInvalid("price", "gte", "must not be negative")
+	}
+	return nil
+}
+
+func CreateProduct(s *database.Stores, c *gin.Context) {
+	var product database.Product
+	if !bindJSON(c, &product) {
+		return
+	}
+	if err := validatePrice(product.Price); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := s.Products.Create(c, product); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Product created!"})
+}
+
+// GetProducts accepts ?name_prefix=, ?min_price=, ?max_price= and ?currency=
+// filters plus the usual paging parameters.
+func GetProducts(s *database.Stores, c *gin.Context) {
+	filter, err := productFilter(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	page, err := pageRequest(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	products, err := s.Products.List(c, filter, page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, products)
+}
+
+func GetProductByUPC(s *database.Stores, c *gin.Context) {
+	product, err := s.Products.Get(c, c.Param("upc"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+func UpdateProductByUPC(s *database.Stores, c *gin.Context) {
+	var product database.Product
+	if !bindJSON(c, &product) {
+		return
+	}
+	if err := validatePrice(product.Price); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	product.UPC = c.Param("upc")
+	if err := s.Products.Update(c, product); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product updated!"})
+}
+
+func DeleteProductByUPC(s *database.Stores, c *gin.Context) {
+	if err := s.Products.Delete(c, c.Param("upc")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product deleted!"})
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"fuzzy-succotash-balance/main.go/database"
+)
+
+func TestProductPrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		price     any
+		omitPrice bool
+		want      int
+		wantPrice database.Money
+	}{
+		{name: "string amount", price: map[string]string{"amount": "12.34", "currency": "EUR"}, want: http.StatusCreated, wantPrice: database.NewMoney(1234, "EUR")},
+		{name: "default currency", price: map[string]any{"amount": 5}, want: http.StatusCreated, wantPrice: database.NewMoney(500, database.DefaultCurrency)},
+		{name: "missing price", omitPrice: true, want: http.StatusUnprocessableEntity},
+		{name: "null price", price: nil, want: http.StatusUnprocessableEntity},
+		{name: "negative price", price: map[string]string{"amount": "-1.00"}, want: http.StatusUnprocessableEntity},
+		{name: "too many decimals", price: map[string]string{"amount": "1.001"}, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			admin := s.addUser("admin-1", database.RoleAdmin)
+			body := map[string]any{"upc": "upc-1", "name": "Widget"}
+			if !tt.omitPrice {
+				body["price"] = tt.price
+			}
+
+			for _, req := range []struct{ method, path string }{{http.MethodPost, "/products"}, {http.MethodPut, "/products/upc-1"}} {
+				w := s.do(req.method, req.path, admin, body)
+				want := tt.want
+				if req.method == http.MethodPut && want == http.StatusCreated {
+					want = http.StatusOK
+				}
+				if w.Code != want {
+					t.Fatalf("%s %s: status = %d, want %d; body %s", req.method, req.path, w.Code, want, w.Body.String())
+				}
+			}
+			if tt.want != http.StatusCreated {
+				return
+			}
+			product, err := s.stores.Products.Get(context.Background(), "upc-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if product.Price != tt.wantPrice {
+				t.Errorf("price = %v, want %v", product.Price, tt.wantPrice)
+			}
+		})
+	}
+}
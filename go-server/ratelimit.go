@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/config"
+	"fuzzy-succotash-balance/main.go/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimits are the rate limit middleware for each group of routes. Routes
+// outside every group, such as the probes and /metrics, are not throttled.
+type RateLimits struct {
+	Auth gin.HandlerFunc // login, registration and account recovery
+	API  gin.HandlerFunc // everything else, with reads and writes limited apart
+}
+
+// NewRateLimits builds the middleware for each route group from cfg.
+func NewRateLimits(limiter database.RateLimiter, cfg config.RateLimitConfig) RateLimits {
+	return RateLimits{
+		Auth: RateLimit(limiter, "auth", limitFor(cfg.Auth)),
+		API: byMethod(
+			RateLimit(limiter, "read", limitFor(cfg.Read)),
+			RateLimit(limiter, "write", limitFor(cfg.Write)),
+		),
+	}
+}
+
+func limitFor(rule config.RateLimitRule) database.RateLimit {
+	return database.PerMinute(rule.PerMinute, rule.Burst)
+}
+
+// byMethod runs read for GET and HEAD requests and write for the rest.
+func byMethod(read gin.HandlerFunc, write gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			read(c)
+			return
+		}
+		write(c)
+	}
+}
+
+// RateLimit throttles each caller of the routes it is attached to with the
+// token bucket class. Callers are identified by user ID when VerifyJWT has
+// authenticated them and by client IP otherwise, so it must run after
+// VerifyJWT.
+func RateLimit(limiter database.RateLimiter, class string, limit database.RateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := class + ":ip:" + c.ClientIP()
+		if claims := ClaimsFromContext(c); claims != nil {
+			key = class + ":user:" + claims.ID
+		}
+
+		decision, err := limiter.Allow(c, key, limit)
+		if err != nil {
+			// Fail open: an unavailable limiter should not take the API down.
+			Logger(c).Error("rate limiter unavailable", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+
+		if !decision.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+			abortError(c, errRateLimited)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimitPruneInterval is how often PruneRateLimits clears refilled
+// buckets.
+const RateLimitPruneInterval = 10 * time.Minute
+
+// PruneRateLimits clears refilled buckets every RateLimitPruneInterval until
+// ctx is done, so the limiter only holds callers seen recently.
+func PruneRateLimits(ctx context.Context, limiter database.RateLimiter) {
+	pruneEvery(ctx, RateLimitPruneInterval, "rate limits", limiter.Prune)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
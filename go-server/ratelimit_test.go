@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"fuzzy-succotash-balance/main.go/config"
+	"fuzzy-succotash-balance/main.go/database"
+)
+
+func TestRateLimit(t *testing.T) {
+	limits := config.RateLimitConfig{
+		Auth:  config.RateLimitRule{PerMinute: 1, Burst: 2},
+		Read:  config.RateLimitRule{PerMinute: 1, Burst: 2},
+		Write: config.RateLimitRule{PerMinute: 1, Burst: 2},
+	}
+
+	t.Run("auth routes share one bucket", func(t *testing.T) {
+		s := newLimitedTestServer(t, limits)
+		for i, path := range []string{"/login", "/register"} {
+			w := s.do(http.MethodPost, path, "", map[string]string{})
+			if w.Code == http.StatusTooManyRequests {
+				t.Fatalf("request %d throttled early", i+1)
+			}
+		}
+		w := s.do(http.MethodPost, "/password/forgot", "", map[string]string{})
+		if w.Code != http.StatusTooManyRequests || decodeError(t, w).Code != "rate_limited" {
+			t.Fatalf("status = %d, body %s; want 429 rate_limited", w.Code, w.Body.String())
+		}
+		for _, header := range []string{"Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"} {
+			if w.Header().Get(header) == "" {
+				t.Errorf("%s header missing", header)
+			}
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+			t.Errorf("X-RateLimit-Remaining = %s, want 0", got)
+		}
+	})
+
+	t.Run("reads and writes are limited apart", func(t *testing.T) {
+		s := newLimitedTestServer(t, limits)
+		token := s.addUser("customer-1", database.RoleCustomer)
+		for range 2 {
+			s.do(http.MethodPost, "/chats", token, map[string]any{})
+		}
+		if w := s.do(http.MethodPost, "/chats", token, map[string]any{}); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("third write: status = %d, want 429", w.Code)
+		}
+		if w := s.do(http.MethodGet, "/products", token, nil); w.Code != http.StatusOK {
+			t.Errorf("read after writes: status = %d, want 200", w.Code)
+		}
+	})
+
+	t.Run("callers are limited apart", func(t *testing.T) {
+		s := newLimitedTestServer(t, limits)
+		first := s.addUser("customer-1", database.RoleCustomer)
+		second := s.addUser("customer-2", database.RoleCustomer)
+		for range 3 {
+			s.do(http.MethodGet, "/products", first, nil)
+		}
+		if w := s.do(http.MethodGet, "/products", second, nil); w.Code != http.StatusOK {
+			t.Errorf("other user: status = %d, want 200", w.Code)
+		}
+		if w := s.do(http.MethodGet, "/health", "", nil); w.Code != http.StatusOK {
+			t.Errorf("anonymous caller: status = %d, want 200", w.Code)
+		}
+	})
+
+	t.Run("probes and metrics are not limited", func(t *testing.T) {
+		s := newLimitedTestServer(t, limits)
+		token := s.addUser("admin-1", database.RoleAdmin)
+		for _, path := range []string{"/livez", "/readyz", "/metrics"} {
+			for range 3 {
+				w := s.do(http.MethodGet, path, token, nil)
+				if w.Code == http.StatusTooManyRequests || w.Header().Get("X-RateLimit-Limit") != "" {
+					t.Fatalf("%s: status = %d, headers %v; want it unthrottled", path, w.Code, w.Header())
+				}
+			}
+		}
+	})
+}
@@ -5,118 +5,198 @@ import (
 	"fmt"
 	"net/http"
 
+	"fuzzy-succotash-balance/main.go/config"
 	"fuzzy-succotash-balance/main.go/database"
+	"fuzzy-succotash-balance/main.go/mailer"
 
 	"github.com/gin-gonic/gin"
 )
 
-func setupRoutes(r *gin.Engine, port string, db *sql.DB) {
-	r.GET("/health", func(c *gin.Context) {
+func setupRoutes(r *gin.Engine, port string, db *sql.DB, limits RateLimits) {
+	// Probes and scrapes come from infrastructure on a schedule, so they
+	// are not throttled. Metrics describe internals, so only admins see them.
+	r.GET("/livez", Livez)
+	r.GET("/readyz", func(c *gin.Context) {
+		Readyz(db, c)
+	})
+	r.GET("/metrics", RequireRole(database.RoleAdmin), MetricsHandler())
+
+	api := r.Group("", limits.API)
+	api.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"msg": fmt.Sprintf("Drinking Gin on %s", port),
 		})
 	})
-	r.GET("/favicon.ico", func(c *gin.Context) {
+	api.GET("/health/details", RequireRole(database.RoleAdmin), func(c *gin.Context) {
+		HealthDetails(db, c)
+	})
+	api.GET("/favicon.ico", func(c *gin.Context) {
 		c.Status(204) // No Content
 	})
-	r.GET("/apple-touch-icon.png", func(c *gin.Context) {
+	api.GET("/apple-touch-icon.png", func(c *gin.Context) {
 		c.Status(204)
 	})
-	r.GET("/apple-touch-icon-precomposed.png", func(c *gin.Context) {
+	api.GET("/apple-touch-icon-precomposed.png", func(c *gin.Context) {
 		c.Status(204)
 	})
-	r.POST("/drop/:table", func(c *gin.Context) {
-		table := c.Param("table")
-		database.DropTable(db, c, table)
-	})
-	r.POST("/create/:table", func(c *gin.Context) {
+	api.POST("/drop/:table", RequireRole(database.RoleAdmin), func(c *gin.Context) {
 		table := c.Param("table")
-		database.CreateTable(db, c, table)
+		if err := database.DropTable(c, db, table); err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Table dropped successfully"})
 	})
 }
 
-func addUserRoutes(r *gin.Engine, db *sql.DB) {
-	r.POST("/login", func(c *gin.Context) {
-		database.Login(db, c)
+func addUserRoutes(r *gin.Engine, s *database.Stores, mail mailer.Mailer, auth config.AuthConfig, limits RateLimits) {
+	public := r.Group("", limits.Auth)
+	api := r.Group("", limits.API)
+
+	public.POST("/login", func(c *gin.Context) {
+		Login(s, auth, c)
+	})
+	public.POST("/register", func(c *gin.Context) {
+		CreateUser(s, mail, c)
+	})
+	public.GET("/verify", func(c *gin.Context) {
+		VerifyEmail(s, c)
+	})
+	public.POST("/verify/resend", func(c *gin.Context) {
+		ResendVerification(s, mail, c)
+	})
+	api.POST("/auth/refresh", VerifyRefreshToken(auth), func(c *gin.Context) {
+		RefreshToken(s, auth, c)
+	})
+	api.POST("/logout", VerifyRefreshToken(auth), func(c *gin.Context) {
+		Logout(s, c)
+	})
+	api.GET("/admin/login-failures", RequireRole(database.RoleAdmin), func(c *gin.Context) {
+		GetLoginFailures(s, c)
+	})
+	public.POST("/password/forgot", func(c *gin.Context) {
+		ForgotPassword(s, mail, c)
+	})
+	public.POST("/password/reset", func(c *gin.Context) {
+		ResetPassword(s, c)
 	})
-	r.POST("/register", func(c *gin.Context) {
-		database.CreateUser(db, c)
+	api.GET("/users", func(c *gin.Context) {
+		GetUsers(s, c)
 	})
-	r.GET("/users", func(c *gin.Context) {
-		database.GetUsers(db, c)
+	api.GET("/users/:id", func(c *gin.Context) {
+		GetUserByID(s, c)
 	})
-	r.GET("/users/:id", func(c *gin.Context) {
-		database.GetUserByID(db, c)
+	api.PUT("/users/:id", RequireSelfOrRole("id", database.RoleAdmin), func(c *gin.Context) {
+		UpdateUserByID(s, mail, c)
 	})
-	r.PUT("/users/:id", func(c *gin.Context) {
-		database.UpdateUserByID(db, c)
+	api.PUT("/users/:id/password", RequireSelfOrRole("id"), func(c *gin.Context) {
+		ChangePassword(s, c)
 	})
-	r.DELETE("/users/:id", func(c *gin.Context) {
-		database.DeleteUserByID(db, c)
+	api.PUT("/users/:id/role", RequireRole(database.RoleAdmin), func(c *gin.Context) {
+		UpdateUserRole(s, c)
+	})
+	api.GET("/users/:id/orders", RequireSelfOrRole("id", database.RoleStaff, database.RoleAdmin), func(c *gin.Context) {
+		GetOrdersByUser(s, c)
+	})
+	api.DELETE("/users/:id", RequireSelfOrRole("id", database.RoleAdmin), func(c *gin.Context) {
+		DeleteUserByID(s, c)
 	})
 }
 
-func addProductRoutes(r *gin.Engine, db *sql.DB) {
-	r.GET("/products", func(c *gin.Context) {
-		database.GetProducts(db, c)
+func addProductRoutes(r *gin.Engine, s *database.Stores, limits RateLimits) {
+	api := r.Group("", limits.API)
+
+	api.GET("/products", func(c *gin.Context) {
+		GetProducts(s, c)
 	})
-	r.POST("/products", func(c *gin.Context) {
-		database.CreateProduct(db, c)
+	api.POST("/products", RequireRole(database.RoleAdmin), func(c *gin.Context) {
+		CreateProduct(s, c)
 	})
-	r.GET("/products/:upc", func(c *gin.Context) {
-		database.GetProductByUPC(db, c)
+	api.GET("/products/:upc", func(c *gin.Context) {
+		GetProductByUPC(s, c)
 	})
-	r.PUT("/products/:upc", func(c *gin.Context) {
-		database.UpdateProductByUPC(db, c)
+	api.PUT("/products/:upc", RequireRole(database.RoleAdmin), func(c *gin.Context) {
+		UpdateProductByUPC(s, c)
 	})
-	r.DELETE("/products/:upc", func(c *gin.Context) {
-		database.DeleteProductByUPC(db, c)
+	api.DELETE("/products/:upc", RequireRole(database.RoleAdmin), func(c *gin.Context) {
+		DeleteProductByUPC(s, c)
 	})
 }
 
-func addOrderRoutes(r *gin.Engine, db *sql.DB) {
-	r.GET("/orders", func(c *gin.Context) {
-		database.GetOrders(db, c)
+func addOrderRoutes(r *gin.Engine, s *database.Stores, limits RateLimits) {
+	api := r.Group("", limits.API)
+
+	api.GET("/orders", RequireRole(database.RoleStaff, database.RoleAdmin), func(c *gin.Context) {
+		GetOrders(s, c)
 	})
-	r.POST("/orders", func(c *gin.Context) {
-		database.CreateOrder(db, c)
+	api.POST("/orders", func(c *gin.Context) {
+		CreateOrder(s, c)
 	})
-	r.GET("/orders/:orderNumber", func(c *gin.Context) {
-		database.GetOrderByNumber(db, c)
+	api.GET("/orders/:orderNumber", RequireOrderOwner(s, database.RoleStaff, database.RoleAdmin), func(c *gin.Context) {
+		GetOrderByNumber(s, c)
 	})
-	r.PUT("/orders/:orderNumber", func(c *gin.Context) {
-		database.UpdateOrderByNumber(db, c)
+	api.PUT("/orders/:orderNumber", RequireRole(database.RoleStaff, database.RoleAdmin), func(c *gin.Context) {
+		UpdateOrderByNumber(s, c)
 	})
-	r.DELETE("/orders/:orderNumber", func(c *gin.Context) {
-		database.DeleteOrderByNumber(db, c)
+	api.PATCH("/orders/:orderNumber/status", RequireOrderOwner(s, database.RoleStaff, database.RoleAdmin), func(c *gin.Context) {
+		UpdateOrderStatus(s, c)
+	})
+	api.GET("/orders/:orderNumber/history", RequireOrderOwner(s, database.RoleStaff, database.RoleAdmin), func(c *gin.Context) {
+		GetOrderHistory(s, c)
+	})
+	api.DELETE("/orders/:orderNumber", RequireOrderOwner(s, database.RoleStaff, database.RoleAdmin), func(c *gin.Context) {
+		DeleteOrderByNumber(s, c)
 	})
 }
 
-func addChatMessageingRoutes(r *gin.Engine, db *sql.DB) {
+func addChatMessageingRoutes(r *gin.Engine, s *database.Stores, events database.EventBus, hub *Hub, limits RateLimits) {
+	api := r.Group("", limits.API)
 
-	r.POST("/chats", func(c *gin.Context) {
-		database.CreateChat(db, c)
+	api.POST("/chats", func(c *gin.Context) {
+		CreateChat(s, c)
+	})
+	api.POST("/messages", func(c *gin.Context) {
+		CreateMessage(s, events, c)
 	})
-	r.POST("/messages", func(c *gin.Context) {
-		database.CreateMessage(db, c)
+	api.GET("/chats", func(c *gin.Context) {
+		GetAllChats(s, c)
 	})
-	r.GET("/chats", func(c *gin.Context) {
-		database.GetAllChats(db, c)
+	api.GET("/users/:id/chats", RequireSelfOrRole("id", database.RoleAdmin), func(c *gin.Context) {
+		GetUserChats(s, c)
 	})
-	r.GET("/chats/:chatID", func(c *gin.Context) {
-		database.GetChatByID(db, c)
+	api.GET("/chats/:chatID", RequireChatMember(s, database.RoleAdmin), func(c *gin.Context) {
+		GetChatByID(s, c)
 	})
-	r.GET("/chats/:chatID/messages", func(c *gin.Context) {
-		database.GetChatWithMessages(db, c)
+	api.GET("/chats/:chatID/messages", RequireChatMember(s, database.RoleAdmin), func(c *gin.Context) {
+		GetChatWithMessages(s, c)
 	})
-	r.PUT("/chats/:chatID", func(c *gin.Context) {
-		database.UpdateChatByID(db, c)
+	api.GET("/chats/:chatID/ws", RequireChatMember(s, database.RoleAdmin), hub.ServeChat)
+	api.POST("/chats/:chatID/read", RequireChatMember(s), func(c *gin.Context) {
+		MarkChatRead(s, events, c)
+	})
+	api.POST("/chats/:chatID/members", RequireChatMember(s, database.RoleAdmin), func(c *gin.Context) {
+		AddChatMember(s, events, c)
+	})
+	api.DELETE("/chats/:chatID/members/:userID", RequireChatMember(s, database.RoleAdmin), func(c *gin.Context) {
+		RemoveChatMember(s, events, c)
 	})
 
-	r.DELETE("/chats/:chatID", func(c *gin.Context) {
-		database.DeleteChatByID(db, c)
+	api.DELETE("/chats/:chatID", RequireChatOwner(s, database.RoleAdmin), func(c *gin.Context) {
+		DeleteChatByID(s, events, c)
+	})
+	api.PUT("/messages/:messageID", RequireMessageSender(s), func(c *gin.Context) {
+		UpdateMessageByID(s, events, c)
+	})
+	api.GET("/messages/:messageID/edits", RequireMessageMember(s, database.RoleAdmin), func(c *gin.Context) {
+		GetMessageEdits(s, c)
+	})
+	api.DELETE("/messages/:messageID", RequireMessageModerator(s, database.RoleAdmin), func(c *gin.Context) {
+		DeleteMessageByID(s, events, c)
+	})
+	api.PUT("/messages/:messageID/reactions/:emoji", RequireMessageMember(s), func(c *gin.Context) {
+		AddReaction(s, events, c)
 	})
-	r.DELETE("/messages/:messageID", func(c *gin.Context) {
-		database.DeleteMessageByID(db, c)
+	api.DELETE("/messages/:messageID/reactions/:emoji", RequireMessageMember(s), func(c *gin.Context) {
+		RemoveReaction(s, events, c)
 	})
 }
@@ -1,33 +1,133 @@
 package server
 
 import (
+	"context"
 	"database/sql"
-	"log"
-	"os"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"fuzzy-succotash-balance/main.go/config"
 	"fuzzy-succotash-balance/main.go/database"
+	"fuzzy-succotash-balance/main.go/mailer"
+	"fuzzy-succotash-balance/main.go/metrics"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/lib/pq"
 )
 
-func StartServer(db *sql.DB) {
-	log.Println("Starting Server container")
+// StartServer serves until SIGINT or SIGTERM, then stops accepting
+// connections and waits up to cfg.Server.ShutdownTimeout for in-flight
+// requests to finish. The caller owns db and closes it afterwards.
+func StartServer(db *sql.DB, cfg config.Config) error {
+	slog.Info("starting server", "port", cfg.Server.Port)
 
-	port := os.Getenv("GIN_PORT")
+	port := cfg.Server.Port
 
-	r := gin.Default()
-	r.Use(database.VerifyJWT())
+	r := gin.New()
+	r.Use(RequestID(), AccessLog(), Recovery())
+	r.Use(Metrics())
+	r.Use(VerifyJWT(cfg.Auth))
+	r.NoRoute(func(c *gin.Context) { respondError(c, errRouteNotFound) })
 	err := r.SetTrustedProxies([]string{"172.16.0.0/12"})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	setupRoutes(r, port, db)
-	addUserRoutes(r, db)
-	addProductRoutes(r, db)
-	addOrderRoutes(r, db)
-	addChatMessageingRoutes(r, db)
+	database.QueryObserver = ObserveQuery
+	metrics.RegisterDB(db)
+	stores := database.NewPostgresStores(db)
 
-	r.Run(port)
+	// Limits are shared through Postgres by default so they hold across
+	// replicas; the memory backend keeps them per process.
+	limiter := database.NewPostgresRateLimiter(db)
+	if cfg.RateLimit.Backend == "memory" {
+		limiter = database.NewMemoryRateLimiter()
+	}
+	limits := NewRateLimits(limiter, cfg.RateLimit)
+
+	events, err := database.NewPostgresEventBus(db, cfg.Database.ConnInfo())
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+	hub := NewHub(stores.Chats, events)
+
+	// Config validation only allows the file and log mailers in
+	// development.
+	var mail mailer.Mailer
+	switch {
+	case cfg.Mail.SMTPHost != "":
+		mail = mailer.NewSMTPMailer(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.From)
+	case cfg.Mail.Dir != "":
+		if mail, err = mailer.NewFileMailer(cfg.Mail.Dir); err != nil {
+			return err
+		}
+	default:
+		mail = mailer.NewLogMailer()
+	}
+
+	setupRoutes(r, port, db, limits)
+	addUserRoutes(r, stores, mail, cfg.Auth, limits)
+	addProductRoutes(r, stores, limits)
+	addOrderRoutes(r, stores, limits)
+	addChatMessageingRoutes(r, stores, events, hub, limits)
+
+	srv := &http.Server{
+		Addr:              port,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	// Shutdown does not wait for hijacked connections, so close the
+	// WebSockets ourselves.
+	srv.RegisterOnShutdown(hub.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go PruneRateLimits(ctx, limiter)
+	go PruneLogins(ctx, stores.Logins)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down, draining requests", "timeout", cfg.Server.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	slog.Info("server stopped")
+	return nil
+}
+
+// pruneEvery calls prune every interval until ctx is done, logging failures
+// as pruning what.
+func pruneEvery(ctx context.Context, interval time.Duration, what string, prune func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := prune(ctx); err != nil {
+				slog.Error("pruning "+what, "error", err)
+			}
+		}
+	}
 }
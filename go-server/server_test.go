@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/config"
+	"fuzzy-succotash-balance/main.go/database"
+	"fuzzy-succotash-balance/main.go/mailer"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testAuth = config.AuthConfig{
+	TokenSecret:        "test access secret",
+	RefreshTokenSecret: "test refresh secret",
+	AccessTokenTTL:     time.Minute,
+	RefreshTokenTTL:    time.Hour,
+}
+
+// testRateLimits are high enough that only tests about rate limiting hit
+// them.
+var testRateLimits = config.RateLimitConfig{
+	Auth:  config.RateLimitRule{PerMinute: 1000, Burst: 1000},
+	Read:  config.RateLimitRule{PerMinute: 1000, Burst: 1000},
+	Write: config.RateLimitRule{PerMinute: 1000, Burst: 1000},
+}
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// recordingMailer keeps sent messages for assertions.
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) Sent() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mailer.Message(nil), m.sent...)
+}
+
+// testServer is the API wired to memory stores, as StartServer wires it to
+// Postgres.
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	stores *database.Stores
+	mail   *recordingMailer
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newLimitedTestServer(t, testRateLimits)
+}
+
+// newLimitedTestServer is newTestServer with rate limits from cfg. The
+// database behind the health routes is unreachable, so /readyz fails.
+func newLimitedTestServer(t *testing.T, cfg config.RateLimitConfig) *testServer {
+	t.Helper()
+	stores := database.NewMemoryStores()
+	mail := &recordingMailer{}
+	events := database.NewMemoryEventBus()
+	t.Cleanup(func() { events.Close() })
+	db, err := sql.Open("postgres", "host=/nonexistent dbname=test sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	limits := NewRateLimits(database.NewMemoryRateLimiter(), cfg)
+
+	r := gin.New()
+	r.Use(RequestID(), VerifyJWT(testAuth))
+	r.NoRoute(func(c *gin.Context) { respondError(c, errRouteNotFound) })
+	setupRoutes(r, ":test", db, limits)
+	addUserRoutes(r, stores, mail, testAuth, limits)
+	addProductRoutes(r, stores, limits)
+	addOrderRoutes(r, stores, limits)
+	addChatMessageingRoutes(r, stores, events, NewHub(stores.Chats, events), limits)
+
+	return &testServer{t: t, router: r, stores: stores, mail: mail}
+}
+
+// addUser stores a verified user with role and returns an access token for
+// them.
+func (s *testServer) addUser(id string, role database.Role) string {
+	s.t.Helper()
+	user := database.User{ID: id, Name: id, Email: id + "@example.com", Verified: true, Role: role}
+	if err := s.stores.Users.Create(context.Background(), user); err != nil {
+		s.t.Fatal(err)
+	}
+	return s.token(user)
+}
+
+func (s *testServer) token(user database.User) string {
+	s.t.Helper()
+	token, err := database.NewAccessToken(testAuth, database.UserClaims{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token
+}
+
+// do sends a request with token as the bearer token, if set, and body
+// encoded as JSON, if not nil.
+func (s *testServer) do(method string, path string, token string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeError decodes an error envelope, failing the test if the body is
+// not one.
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) ErrorResponse {
+	t.Helper()
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code == "" {
+		t.Fatalf("body %q is not an error envelope", w.Body.String())
+	}
+	return resp
+}
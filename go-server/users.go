@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fuzzy-succotash-balance/main.go/config"
+	"fuzzy-succotash-balance/main.go/database"
+	"fuzzy-succotash-balance/main.go/mailer"
+	"fuzzy-succotash-balance/main.go/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid/v5"
+)
+
+// PublicUser is how a user appears to other users.
+type PublicUser struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Avatar    string    `json:"avatar"`
+	Online    bool      `json:"online"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PrivateUser is how a user appears to themselves and to admins.
+type PrivateUser struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Email     string        `json:"email"`
+	Avatar    string        `json:"avatar"`
+	Online    bool          `json:"online"`
+	Verified  bool          `json:"verified"`
+	Role      database.Role `json:"role"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+func publicUser(user database.User) PublicUser {
+	return PublicUser{
+		ID:        user.ID,
+		Name:      user.Name,
+		Avatar:    user.Avatar,
+		Online:    user.Online,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func privateUser(user database.User) PrivateUser {
+	return PrivateUser{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Avatar:    user.Avatar,
+		Online:    user.Online,
+		Verified:  user.Verified,
+		Role:      user.Role,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+// userView picks the shape of user that the caller is allowed to see.
+func userView(c *gin.Context, user database.User) any {
+	claims := ClaimsFromContext(c)
+	if claims != nil && (claims.ID == user.ID || claims.HasRole(database.RoleAdmin)) {
+		return privateUser(user)
+	}
+	return publicUser(user)
+}
+
+// RegisterRequest's password minimum matches database.MinPasswordLength,
+// which password changes and resets enforce.
+type RegisterRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+	Avatar   string `json:"avatar"`
+}
+
+// CreateUser registers a new customer and emails them a verification link.
+func CreateUser(s *database.Stores, mail mailer.Mailer, c *gin.Context) {
+	var req RegisterRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	id, err := uuid.NewV1()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// ⚡️ Hash the password before inserting
+	hashedPassword, err := database.HashedPassword(req.Password)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	user := database.User{
+		ID:       "user_" + id.String(),
+		Name:     req.Name,
+		Email:    database.NormalizeEmail(req.Email),
+		Password: hashedPassword,
+		Avatar:   req.Avatar,
+		Role:     database.RoleCustomer,
+	}
+
+	if err := s.Users.Create(c, user); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// The account exists either way; a failed email can be retried through
+	// /verify/resend.
+	if err := sendVerificationEmail(s, mail, c, user); err != nil {
+		Logger(c).Error("verification email failed", "error", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User created!"})
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func Login(s *database.Stores, auth config.AuthConfig, c *gin.Context) {
+	var req LoginRequest
+
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := database.Authenticate(c, s.Users, s.Logins, req.Email, req.Password, c.ClientIP())
+	var lockout *database.LockoutError
+	switch {
+	case errors.As(err, &lockout):
+		metrics.Logins.WithLabelValues("locked").Inc()
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(lockout.RetryAfter)))
+	case errors.Is(err, database.ErrInvalidCredentials):
+		metrics.Logins.WithLabelValues("failure").Inc()
+	case err == nil:
+		metrics.Logins.WithLabelValues("success").Inc()
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if auth.RequireVerifiedEmail && !user.Verified {
+		respondError(c, database.ErrEmailNotVerified)
+		return
+	}
+
+	tokens, err := database.NewSession(c, auth, s.Tokens, user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Login Success",
+		"token":        tokens.Token,
+		"refreshToken": tokens.RefreshToken,
+		"user":         privateUser(user),
+	})
+}
+
+// GetLoginFailures lists the failed login audit, newest first unless ?sort=
+// says otherwise. It accepts ?email=, ?ip= and ?since= filters.
+func GetLoginFailures(s *database.Stores, c *gin.Context) {
+	page, err := pageRequest(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if c.Query("sort") == "" {
+		page.Desc = true
+	}
+
+	filter := database.LoginFailureFilter{Email: c.Query("email"), IP: c.Query("ip")}
+	if filter.Since, err = queryTime(c, "since"); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	failures, err := s.Logins.ListFailures(c, filter, page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, failures)
+}
+
+// RefreshToken must run after VerifyRefreshToken.
+// LoginPruneInterval is how often PruneLogins clears expired lockout state.
+const LoginPruneInterval = 10 * time.Minute
+
+// PruneLogins clears lockout counters whose window has passed and audit rows
+// older than database.LoginFailureRetention every LoginPruneInterval until
+// ctx is done.
+func PruneLogins(ctx context.Context, logins database.LoginStore) {
+	pruneEvery(ctx, LoginPruneInterval, "login failures", func(ctx context.Context) error {
+		return logins.Prune(ctx, database.FailureWindow, database.LoginFailureRetention)
+	})
+}
+
+func RefreshToken(s *database.Stores, auth config.AuthConfig, c *gin.Context) {
+	tokens, err := database.RotateRefreshToken(c, auth, s.Tokens, s.Users, refreshClaimsFromContext(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Token Refreshed",
+		"token":        tokens.Token,
+		"refreshToken": tokens.RefreshToken,
+	})
+}
+
+// Logout must run after VerifyJWT and VerifyRefreshToken. It revokes the
+// refresh token family of the presented token.
+func Logout(s *database.Stores, c *gin.Context) {
+	claims := refreshClaimsFromContext(c)
+	if err := s.Tokens.RevokeFamily(c, claims.Id, ClaimsFromContext(c).ID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+func GetUsers(s *database.Stores, c *gin.Context) {
+	page, err := pageRequest(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	users, err := s.Users.List(c, userFilter(c), page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	views := make([]any, len(users.Items))
+	for i, user := range users.Items {
+		views[i] = userView(c, user)
+	}
+	c.JSON(http.StatusOK, database.Page[any]{Items: views, NextCursor: users.NextCursor})
+}
+
+func GetUserByID(s *database.Stores, c *gin.Context) {
+	user, err := s.Users.GetByID(c, c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, userView(c, user))
+}
+
+// UpdateUserRequest holds the profile fields a user may change. Fields left
+// out of the body keep their current value.
+type UpdateUserRequest struct {
+	Name   *string `json:"name"`
+	Email  *string `json:"email" binding:"omitempty,email"`
+	Avatar *string `json:"avatar"`
+	Online *bool   `json:"online"`
+}
+
+// UpdateUserByID saves profile changes. A new email address is unverified
+// until the user follows the link sent to it.
+func UpdateUserByID(s *database.Stores, mail mailer.Mailer, c *gin.Context) {
+	var req UpdateUserRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := s.Users.GetByID(c, c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+	emailChanged := req.Email != nil && database.NormalizeEmail(*req.Email) != user.Email
+	if emailChanged {
+		user.Email = database.NormalizeEmail(*req.Email)
+		user.Verified = false
+	}
+	if req.Avatar != nil {
+		user.Avatar = *req.Avatar
+	}
+	if req.Online != nil {
+		user.Online = *req.Online
+	}
+
+	if err := s.Users.Update(c, user); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// The new address must be confirmed; a failed email can be retried
+	// through /verify/resend.
+	if emailChanged {
+		token, err := database.NewEmailVerification(c, s.Tokens, user)
+		if err == nil {
+			err = mailVerificationLink(mail, c, user, token)
+		}
+		if err != nil {
+			Logger(c).Error("verification email failed", "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated!"})
+}
+
+type UpdateRoleRequest struct {
+	Role database.Role `json:"role"`
+}
+
+func UpdateUserRole(s *database.Stores, c *gin.Context) {
+	var req UpdateRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if !req.Role.Valid() {
+		respondError(c, fieldInvalid("role", "valid", "is not a known role"))
+		return
+	}
+
+	if err := s.Users.UpdateRole(c, c.Param("id"), req.Role); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User role updated!"})
+}
+
+func DeleteUserByID(s *database.Stores, c *gin.Context) {
+	if err := s.Users.Delete(c, c.Param("id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted!"})
+}
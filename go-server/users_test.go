@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"fuzzy-succotash-balance/main.go/database"
+)
+
+func TestRegisterPasswordLength(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{password: "", want: http.StatusUnprocessableEntity},
+		{password: strings.Repeat("x", database.MinPasswordLength-1), want: http.StatusUnprocessableEntity},
+		{password: strings.Repeat("x", database.MinPasswordLength), want: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		s := newTestServer(t)
+		w := s.do(http.MethodPost, "/register", "", map[string]string{"name": "Ada", "email": "ada@example.com", "password": tt.password})
+		if w.Code != tt.want {
+			t.Errorf("registering with a %d character password: status = %d, want %d; body %s", len(tt.password), w.Code, tt.want, w.Body.String())
+			continue
+		}
+		if tt.want == http.StatusUnprocessableEntity && decodeError(t, w).Code != "validation_failed" {
+			t.Errorf("registering with a %d character password: body %s, want validation_failed", len(tt.password), w.Body.String())
+		}
+	}
+}
+
+func TestUserViewHidesPassword(t *testing.T) {
+	s := newTestServer(t)
+	token := s.addUser("customer-1", database.RoleCustomer)
+	for _, path := range []string{"/users", "/users/customer-1"} {
+		w := s.do(http.MethodGet, path, token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, w.Code)
+		}
+		if strings.Contains(strings.ToLower(w.Body.String()), "password") {
+			t.Errorf("GET %s exposes a password field: %s", path, w.Body.String())
+		}
+	}
+}
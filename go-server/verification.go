@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"fuzzy-succotash-balance/main.go/database"
+	"fuzzy-succotash-balance/main.go/mailer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendVerificationEmail issues a verification token for user and mails it.
+func sendVerificationEmail(s *database.Stores, mail mailer.Mailer, c *gin.Context, user database.User) error {
+	token, err := database.RequestEmailVerification(c, s.Tokens, user)
+	if err != nil {
+		return err
+	}
+	return mailVerificationLink(mail, c, user, token)
+}
+
+func mailVerificationLink(mail mailer.Mailer, c *gin.Context, user database.User, token string) error {
+	return mail.Send(c, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Confirm your email address by visiting /verify?token=%s\n\nThe link expires in %s.\n",
+			token, database.EmailVerificationTTL),
+	})
+}
+
+func VerifyEmail(s *database.Stores, c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		respondError(c, &queryParamError{"token", "is required"})
+		return
+	}
+
+	if err := database.VerifyEmail(c, s.Users, s.Tokens, token); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified!"})
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+const resendVerificationResponse = "If that account exists and is unverified, a verification email has been sent"
+
+// ResendVerification mails a fresh verification token. Like ForgotPassword it
+// does not reveal whether the email is registered: every outcome is a 202.
+func ResendVerification(s *database.Stores, mail mailer.Mailer, c *gin.Context) {
+	var req ResendVerificationRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := s.Users.GetByEmail(c, req.Email)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && user.Verified) {
+		c.JSON(http.StatusAccepted, gin.H{"message": resendVerificationResponse})
+		return
+	} else if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Throttling and send failures only happen for unverified accounts, so
+	// they are logged rather than reported to the caller.
+	err = sendVerificationEmail(s, mail, c, user)
+	if errors.Is(err, database.ErrVerificationThrottled) {
+		Logger(c).Warn("verification resend throttled", "target_user_id", user.ID)
+	} else if err != nil {
+		Logger(c).Error("verification email failed", "error", err, "target_user_id", user.ID)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": resendVerificationResponse})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"fuzzy-succotash-balance/main.go/database"
+)
+
+func TestEmailChangeNeedsVerification(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         map[string]string
+		wantVerified bool
+		wantMailTo   string
+	}{
+		{name: "new email", body: map[string]string{"email": "ada@new.example.com"}, wantMailTo: "ada@new.example.com"},
+		{name: "same email", body: map[string]string{"email": "customer-1@example.com"}, wantVerified: true},
+		{name: "other fields", body: map[string]string{"name": "Ada"}, wantVerified: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			token := s.addUser("customer-1", database.RoleCustomer)
+
+			if w := s.do(http.MethodPut, "/users/customer-1", token, tt.body); w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+			}
+			user, err := s.stores.Users.GetByID(context.Background(), "customer-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Verified != tt.wantVerified {
+				t.Errorf("verified = %t, want %t", user.Verified, tt.wantVerified)
+			}
+
+			sent := s.mail.Sent()
+			if tt.wantMailTo == "" {
+				if len(sent) != 0 {
+					t.Errorf("sent %d emails, want none", len(sent))
+				}
+				return
+			}
+			if len(sent) != 1 || sent[0].To != tt.wantMailTo {
+				t.Fatalf("sent %+v, want one email to %s", sent, tt.wantMailTo)
+			}
+			link := sent[0].Body[strings.Index(sent[0].Body, "token=")+len("token="):]
+			link = strings.Fields(link)[0]
+			if w := s.do(http.MethodGet, "/verify?token="+link, "", nil); w.Code != http.StatusOK {
+				t.Fatalf("verifying the new email: status %d, body %s", w.Code, w.Body.String())
+			}
+			if user, _ := s.stores.Users.GetByID(context.Background(), "customer-1"); !user.Verified {
+				t.Error("user is not verified after following the link")
+			}
+		})
+	}
+}
+
+func TestResendVerificationDoesNotLeakAccounts(t *testing.T) {
+	s := newTestServer(t)
+	s.addUser("verified-1", database.RoleCustomer)
+	if err := s.stores.Users.Create(context.Background(), database.User{ID: "unverified-1", Name: "unverified-1", Email: "unverified-1@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second resend for the unverified account is throttled.
+	for _, email := range []string{"nobody@example.com", "verified-1@example.com", "unverified-1@example.com", "unverified-1@example.com"} {
+		w := s.do(http.MethodPost, "/verify/resend", "", map[string]string{"email": email})
+		if w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), resendVerificationResponse) {
+			t.Errorf("resend for %s: status %d, body %s; want the generic 202", email, w.Code, w.Body.String())
+		}
+	}
+	if sent := s.mail.Sent(); len(sent) != 1 || sent[0].To != "unverified-1@example.com" {
+		t.Errorf("sent %+v, want one email to the unverified account", sent)
+	}
+}
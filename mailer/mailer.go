@@ -0,0 +1,104 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email such as password reset links.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type logMailer struct{}
+
+// NewLogMailer logs the recipient and subject of each message instead of
+// sending it. Bodies carry reset and verification links, so they are never
+// logged. It is meant for local development.
+func NewLogMailer() Mailer {
+	return logMailer{}
+}
+
+func (logMailer) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "mail not sent", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends mail through an SMTP relay, authenticating with
+// username and password if username is set.
+func NewSMTPMailer(host string, port int, username string, password string, from string) Mailer {
+	m := smtpMailer{addr: net.JoinHostPort(host, fmt.Sprint(port)), from: from}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m smtpMailer) Send(ctx context.Context, msg Message) error {
+	data := format(m.from, msg, time.Now())
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(data))
+}
+
+type fileMailer struct {
+	dir string
+}
+
+// NewFileMailer writes each message to its own .eml file in dir, creating
+// dir if needed.
+func NewFileMailer(dir string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return fileMailer{dir: dir}, nil
+}
+
+func (m fileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	name := fmt.Sprintf("%s_%s.eml", now.Format("20060102T150405.000000000"), sanitize(msg.To))
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(format("", msg, now)), 0o600)
+}
+
+// format renders msg as a plain text RFC 5322 message. The From header is
+// left out if from is empty.
+func format(from string, msg Message, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+	return b.String()
+}
+
+// sanitize keeps an address usable as part of a file name.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '@', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -1,19 +1,100 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
 
+	"fuzzy-succotash-balance/main.go/config"
 	"fuzzy-succotash-balance/main.go/database"
 	"fuzzy-succotash-balance/main.go/go-server"
-	"log"
 )
 
-var db *sql.DB
+// logLevel starts at info so config errors are logged, then follows
+// LOG_LEVEL.
+var logLevel = new(slog.LevelVar)
 
 func main() {
-	log.Println("Starting Fuzzy-Succotash-Balance")
-	db := database.ConnectPSQL(db)
-	database.CreateUpdatedAtTrigger(db)
-	database.CreateUpdatedAtTriggerForTable(db, "users")
-	server.StartServer(db)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})))
+	slog.Info("starting Fuzzy-Succotash-Balance")
+
+	cfg, err := config.Load()
+	if err != nil {
+		fatal(err)
+	}
+	level, _ := cfg.Log.SlogLevel()
+	logLevel.Set(level)
+
+	db, err := database.ConnectPSQL(cfg.Database)
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := run(db, cfg); err != nil {
+		db.Close()
+		fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("closing database", "error", err)
+	}
+}
+
+func fatal(err error) {
+	slog.Error("exiting", "error", err)
+	os.Exit(1)
+}
+
+func run(db *sql.DB, cfg config.Config) error {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		return runMigrate(db, os.Args[2:])
+	}
+
+	if err := cfg.ValidateServer(); err != nil {
+		return err
+	}
+	if err := database.MigrateUp(context.Background(), db); err != nil {
+		return err
+	}
+	return server.StartServer(db, cfg)
+}
+
+// runMigrate handles `migrate up`, `migrate down [steps]` and `migrate status`.
+func runMigrate(db *sql.DB, args []string) error {
+	ctx := context.Background()
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		return database.MigrateUp(ctx, db)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		return database.MigrateDown(ctx, db, steps)
+	case "status":
+		states, err := database.MigrationStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, appliedAt)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
 }